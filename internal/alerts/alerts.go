@@ -0,0 +1,240 @@
+// Package alerts evaluates alert rules on single columns of a column set:
+// the built in alerts.json, or the file in ALERT_RULES. They are evaluated
+// after every run against the snapshot history. An alert is posted to the
+// notifiers of the rule when the rule starts firing and once more when it is
+// resolved; which rules of a set are firing is kept in
+// alerts-state-<set>.json in SNAPSHOT_DIR, so runs of different sets do not
+// overwrite each other's state. Without SNAPSHOT_DIR alerts could not be
+// de-duplicated, so they are not evaluated.
+package alerts
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/notify"
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+)
+
+type alertRule struct {
+	Name   string `json:"name"`
+	Set    string `json:"set"`
+	Column string `json:"column"`
+
+	// Above fires when the count is over it.
+	Above *int `json:"above,omitempty"`
+	// Doubled fires when the count is at least twice the previous one.
+	Doubled bool `json:"doubled,omitempty"`
+	// ChangePercent fires when the count grew by at least that many
+	// percent over Window, or dropped by that many for a negative value.
+	ChangePercent *float64 `json:"change_percent,omitempty"`
+	Window        string   `json:"window,omitempty"`
+	// IncreasingRuns fires when the count went up in each of that many
+	// runs in a row.
+	IncreasingRuns int `json:"increasing_runs,omitempty"`
+
+	// Notify are the names of the webhooks to alert; all of them when
+	// empty.
+	Notify []string `json:"notify,omitempty"`
+}
+
+//go:embed alerts.json
+var defaultAlertRules []byte
+
+func loadAlertRules() ([]alertRule, error) {
+	b := defaultAlertRules
+	if path := os.Getenv("ALERT_RULES"); path != "" {
+		var err error
+		if b, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var rules []alertRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse alert rules: %v", err)
+	}
+	for i, r := range rules {
+		if r.Name == "" {
+			rules[i].Name = r.Set + "/" + r.Column
+		}
+		if r.ChangePercent != nil {
+			if _, err := time.ParseDuration(r.Window); err != nil {
+				return nil, fmt.Errorf("alert rule %s: bad window %q: %v", rules[i].Name, r.Window, err)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// check returns the alert message when the rule fires for the last snapshot
+// of the history, oldest first, and whether it fires.
+func (r alertRule) check(history []snapshots.Snapshot) (string, bool) {
+	if len(history) == 0 {
+		return "", false
+	}
+	current := &history[len(history)-1]
+	count, ok := current.Count(r.Column)
+	if !ok {
+		return "", false
+	}
+
+	if r.Above != nil && count > *r.Above {
+		return fmt.Sprintf("%s exceeded %d (now %d)", r.Column, *r.Above, count), true
+	}
+
+	if r.Doubled && len(history) > 1 {
+		if prev, ok := history[len(history)-2].Count(r.Column); ok && prev > 0 && count >= 2*prev {
+			return fmt.Sprintf("%s doubled (%d -> %d)", r.Column, prev, count), true
+		}
+	}
+
+	if r.ChangePercent != nil {
+		window, _ := time.ParseDuration(r.Window)
+		before := current.Time.Add(-window)
+		for i := len(history) - 2; i >= 0; i-- {
+			if history[i].Time.After(before) {
+				continue
+			}
+			prev, ok := history[i].Count(r.Column)
+			if !ok || prev == 0 {
+				break
+			}
+			change := float64(count-prev) * 100 / float64(prev)
+			if (*r.ChangePercent >= 0 && change >= *r.ChangePercent) || (*r.ChangePercent < 0 && change <= *r.ChangePercent) {
+				return fmt.Sprintf("%s changed %+.0f%% in %s (%d -> %d)", r.Column, change, r.Window, prev, count), true
+			}
+			break
+		}
+	}
+
+	if r.IncreasingRuns > 0 && len(history) > r.IncreasingRuns {
+		increasing := true
+		for i := len(history) - r.IncreasingRuns; i < len(history); i++ {
+			a, okA := history[i-1].Count(r.Column)
+			b, okB := history[i].Count(r.Column)
+			if !okA || !okB || b <= a {
+				increasing = false
+				break
+			}
+		}
+		if increasing {
+			return fmt.Sprintf("%s went up %d runs in a row (now %d)", r.Column, r.IncreasingRuns, count), true
+		}
+	}
+
+	return "", false
+}
+
+// alertState is when each firing rule started firing, by rule name.
+type alertState map[string]time.Time
+
+func alertStatePath(set string) string {
+	return filepath.Join(os.Getenv("SNAPSHOT_DIR"), "alerts-state-"+set+".json")
+}
+
+func loadAlertState(set string) alertState {
+	state := alertState{}
+	b, err := os.ReadFile(alertStatePath(set))
+	if errors.Is(err, os.ErrNotExist) {
+		return state
+	}
+	if err == nil {
+		err = json.Unmarshal(b, &state)
+	}
+	if err != nil {
+		logging.Logger.Warn("ignoring unreadable alert state", "set", set, "err", err)
+	}
+	return state
+}
+
+func (s alertState) save(set string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	path := alertStatePath(set)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Evaluate evaluates the rules of the set against its history, which ends
+// with the current snapshot, and posts the alerts that start firing or are
+// resolved. A rule whose alert could not be posted keeps its state, so the
+// next run posts it again.
+func Evaluate(set string, history []snapshots.Snapshot) error {
+	rules, err := loadAlertRules()
+	if err != nil {
+		return err
+	}
+	var setRules []alertRule
+	for _, r := range rules {
+		if r.Set == set {
+			setRules = append(setRules, r)
+		}
+	}
+	if len(setRules) == 0 || len(history) == 0 {
+		return nil
+	}
+	if os.Getenv("SNAPSHOT_DIR") == "" {
+		return fmt.Errorf("not evaluating %d alert rules of %s: SNAPSHOT_DIR is needed to keep which are firing", len(setRules), set)
+	}
+
+	state := loadAlertState(set)
+	current := history[len(history)-1]
+
+	var errs []error
+	for _, r := range setRules {
+		since, fired := state[r.Name]
+		if _, ok := current.Count(r.Column); !ok {
+			// a column that failed to count neither fires nor resolves
+			logging.Logger.Warn("skipping alert, column not counted", "rule", r.Name, "column", r.Column, "firing", fired)
+			continue
+		}
+
+		msg, firing := r.check(history)
+		switch {
+		case firing && !fired:
+			logging.Logger.Warn("alert", "rule", r.Name, "message", msg)
+			if err := notify.Send(r.Notify, fmt.Sprintf(":rotating_light: sig/node %s: %s", set, msg)); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			state[r.Name] = current.Time
+		case firing:
+			logging.Logger.Info("alert still firing", "rule", r.Name, "since", since)
+		case fired:
+			logging.Logger.Info("alert resolved", "rule", r.Name)
+			if err := notify.Send(r.Notify, fmt.Sprintf(":white_check_mark: sig/node %s: resolved %s, firing since %s", set, r.Name, since.Format("2006-01-02 15:04"))); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			delete(state, r.Name)
+		}
+	}
+
+	errs = append(errs, state.save(set))
+	return errors.Join(errs...)
+}
+
+// History returns the stored history of the set, or just the current
+// snapshot without a store.
+func History(set string, current snapshots.Snapshot) []snapshots.Snapshot {
+	history, err := snapshots.Load(set)
+	if err != nil {
+		logging.Logger.Warn("failed to load snapshot history", "set", set, "err", err)
+	}
+	if n := len(history); n == 0 || !history[n-1].Time.Equal(current.Time) {
+		history = append(history, current)
+	}
+	return history
+}
@@ -0,0 +1,165 @@
+package alerts
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+)
+
+var start = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+// history has a snapshot of column "c" per count, a day apart; a negative
+// count is a column that was not counted.
+func history(counts ...int) []snapshots.Snapshot {
+	var h []snapshots.Snapshot
+	for i, n := range counts {
+		c := snapshots.Column{Name: "c"}
+		if n >= 0 {
+			n := n
+			c.Count = &n
+		}
+		h = append(h, snapshots.Snapshot{Time: start.AddDate(0, 0, i), Columns: []snapshots.Column{c}})
+	}
+	return h
+}
+
+func TestCheck(t *testing.T) {
+	above := 10
+	up50, down50 := 50.0, -50.0
+
+	for _, tc := range []struct {
+		name    string
+		rule    alertRule
+		history []snapshots.Snapshot
+		want    string
+	}{
+		{"no history", alertRule{Above: &above}, nil, ""},
+		{"above", alertRule{Above: &above}, history(11), "c exceeded 10 (now 11)"},
+		{"at the threshold", alertRule{Above: &above}, history(10), ""},
+		{"not counted", alertRule{Above: &above}, history(11, -1), ""},
+		{"doubled", alertRule{Doubled: true}, history(3, 6), "c doubled (3 -> 6)"},
+		{"almost doubled", alertRule{Doubled: true}, history(3, 5), ""},
+		{"doubled from zero", alertRule{Doubled: true}, history(0, 6), ""},
+		{"grew over the window", alertRule{ChangePercent: &up50, Window: "48h"}, history(10, 12, 15), "c changed +50% in 48h (10 -> 15)"},
+		{"grew less than the window", alertRule{ChangePercent: &up50, Window: "48h"}, history(10, 12, 14), ""},
+		{"window older than the history", alertRule{ChangePercent: &up50, Window: "72h"}, history(10, 12, 15), ""},
+		{"dropped", alertRule{ChangePercent: &down50, Window: "24h"}, history(10, 4), "c changed -60% in 24h (10 -> 4)"},
+		{"increasing", alertRule{IncreasingRuns: 2}, history(5, 6, 7), "c went up 2 runs in a row (now 7)"},
+		{"flat run", alertRule{IncreasingRuns: 2}, history(5, 6, 6), ""},
+		{"too few runs", alertRule{IncreasingRuns: 2}, history(6, 7), ""},
+		{"gap in the runs", alertRule{IncreasingRuns: 2}, history(5, -1, 7), ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.rule.Column = "c"
+			msg, firing := tc.rule.check(tc.history)
+			if msg != tc.want || firing != (tc.want != "") {
+				t.Errorf("check = %q, %v; want %q", msg, firing, tc.want)
+			}
+		})
+	}
+}
+
+// setup writes a rule r for set "s" and a rule for set "t", both firing
+// above 10 of column c, keeps the state in a temporary SNAPSHOT_DIR and
+// returns the messages posted to the webhook, which answers with *status.
+func setup(t *testing.T, status *int) *[]string {
+	t.Helper()
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(rules, []byte(`[{"name": "r", "set": "s", "column": "c", "above": 10}, {"set": "t", "column": "c", "above": 10}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ALERT_RULES", rules)
+	t.Setenv("SNAPSHOT_DIR", dir)
+
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		got = append(got, body.Text)
+		w.WriteHeader(*status)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("NOTIFY_WEBHOOKS", srv.URL)
+	return &got
+}
+
+func TestEvaluateStateChanges(t *testing.T) {
+	status := http.StatusOK
+	got := setup(t, &status)
+
+	for _, step := range []struct {
+		name   string
+		counts []int
+		status int
+		err    bool
+		posted string
+		firing bool
+	}{
+		{"quiet", []int{5}, http.StatusOK, false, "", false},
+		{"fires", []int{5, 11}, http.StatusOK, false, "exceeded 10", true},
+		{"still firing", []int{5, 11, 12}, http.StatusOK, false, "", true},
+		{"not counted", []int{5, 11, 12, -1}, http.StatusOK, false, "", true},
+		{"resolve fails", []int{5, 11, 12, 4}, http.StatusInternalServerError, true, "resolved r", true},
+		{"resolve retried", []int{5, 11, 12, 4, 3}, http.StatusOK, false, "resolved r", false},
+		{"fire fails", []int{5, 11}, http.StatusInternalServerError, true, "exceeded 10", false},
+		{"fire retried", []int{5, 11, 13}, http.StatusOK, false, "exceeded 10", true},
+	} {
+		*got = nil
+		status = step.status
+		err := Evaluate("s", history(step.counts...))
+		if (err != nil) != step.err {
+			t.Errorf("%s: Evaluate error = %v, want error %v", step.name, err, step.err)
+		}
+		switch {
+		case step.posted == "" && len(*got) > 0:
+			t.Errorf("%s: posted %q, want nothing", step.name, *got)
+		case step.posted != "" && (len(*got) != 1 || !strings.Contains((*got)[0], step.posted)):
+			t.Errorf("%s: posted %q, want a message with %q", step.name, *got, step.posted)
+		}
+		if _, firing := loadAlertState("s")["r"]; firing != step.firing {
+			t.Errorf("%s: firing = %v, want %v", step.name, firing, step.firing)
+		}
+	}
+}
+
+func TestEvaluateKeepsStatePerSet(t *testing.T) {
+	status := http.StatusOK
+	setup(t, &status)
+
+	if err := Evaluate("s", history(11)); err != nil {
+		t.Fatal(err)
+	}
+	// a run of another set must not drop the firing rule of s
+	if err := Evaluate("t", history(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, firing := loadAlertState("s")["r"]; !firing {
+		t.Error("rule of s is no longer firing after a run of t")
+	}
+}
+
+func TestEvaluateNeedsSnapshotDir(t *testing.T) {
+	status := http.StatusOK
+	got := setup(t, &status)
+	t.Setenv("SNAPSHOT_DIR", "")
+
+	if err := Evaluate("s", history(11)); err == nil {
+		t.Error("Evaluate without SNAPSHOT_DIR succeeded")
+	}
+	if len(*got) > 0 {
+		t.Errorf("posted %q without SNAPSHOT_DIR", *got)
+	}
+	if err := Evaluate("u", history(11)); err != nil {
+		t.Errorf("Evaluate of a set without rules: %v", err)
+	}
+}
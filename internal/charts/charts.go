@@ -0,0 +1,176 @@
+// Package charts draws the history of a column set from the snapshot store
+// as a line chart of every column, for the static dashboard site and the
+// live dashboards. Charts are inline SVG and the pages have no external
+// assets, so they can be served from anywhere. Every column links to its
+// GitHub search.
+package charts
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+)
+
+//go:embed dashboard.html
+var pageTemplate string
+
+var setTitles = map[string]string{
+	"prs":          "PRs",
+	"bugs":         "Bugs",
+	"weekly":       "Weekly",
+	"testfailures": "Test failures",
+}
+
+var colors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+const width, height, margin = 900, 360, 50
+
+// Title returns the display name of a column set.
+func Title(set string) string {
+	if title, ok := setTitles[set]; ok {
+		return title
+	}
+	return set
+}
+
+// Series is the line of one column.
+type Series struct {
+	Name   string
+	Color  string
+	URL    string
+	Points string
+	// Latest is the count of the last snapshot and Current the live one.
+	Latest  *int
+	Current *int
+}
+
+// Chart is the history of one column set.
+type Chart struct {
+	Set   string
+	Title string
+	// URL is the page of the set alone.
+	URL    string
+	From   string
+	To     string
+	Max    int
+	Series []Series
+	// Counted is when the live counts were counted, empty without them.
+	Counted string
+
+	Width, Height, Margin int
+}
+
+// daily keeps the last snapshot of every day, unless that leaves too few to
+// draw a line.
+func daily(history []snapshots.Snapshot) []snapshots.Snapshot {
+	var days []snapshots.Snapshot
+	for i, s := range history {
+		if i+1 < len(history) && history[i+1].Time.UTC().Format("2006-01-02") == s.Time.UTC().Format("2006-01-02") {
+			continue
+		}
+		days = append(days, s)
+	}
+	if len(days) < 2 {
+		return history
+	}
+	return days
+}
+
+// Build lays out the history of the set, oldest first, as a chart with a
+// series for every column of the current counts or, without them, of the
+// latest snapshot.
+func Build(set string, history []snapshots.Snapshot, current *snapshots.Snapshot) Chart {
+	c := Chart{Set: set, Title: Title(set), Width: width, Height: height, Margin: margin, Max: 1}
+
+	var latest *snapshots.Snapshot
+	var first, last time.Time
+	if len(history) > 0 {
+		history = daily(history)
+		latest = &history[len(history)-1]
+		first, last = history[0].Time, latest.Time
+		c.From, c.To = first.UTC().Format("2006-01-02"), last.UTC().Format("2006-01-02")
+	}
+	columns := current
+	if columns == nil {
+		columns = latest
+	} else {
+		c.Counted = current.Time.Format("2006-01-02 15:04 MST")
+	}
+	if columns == nil {
+		return c
+	}
+
+	for _, s := range history {
+		for _, col := range s.Columns {
+			if col.Count != nil && *col.Count > c.Max {
+				c.Max = *col.Count
+			}
+		}
+	}
+
+	span := last.Sub(first)
+	x := func(t time.Time) float64 {
+		if span <= 0 {
+			return float64(width) / 2
+		}
+		return margin + float64(width-2*margin)*float64(t.Sub(first))/float64(span)
+	}
+	y := func(v int) float64 {
+		return height - margin - float64(height-2*margin)*float64(v)/float64(c.Max)
+	}
+
+	for i, col := range columns.Columns {
+		var points []string
+		for _, s := range history {
+			if count, ok := s.Count(col.Name); ok {
+				points = append(points, fmt.Sprintf("%.1f,%.1f", x(s.Time), y(count)))
+			}
+		}
+		series := Series{
+			Name:   col.Name,
+			Color:  colors[i%len(colors)],
+			URL:    ghclient.SearchURL(col.Query),
+			Points: strings.Join(points, " "),
+		}
+		if n, ok := latest.Count(col.Name); ok {
+			series.Latest = &n
+		}
+		if current != nil {
+			series.Current = col.Count
+		}
+		c.Series = append(c.Series, series)
+	}
+	return c
+}
+
+// Link is an entry of the navigation of a page.
+type Link struct {
+	Title string
+	URL   string
+}
+
+// Page is a dashboard page with one or more charts.
+type Page struct {
+	Title string
+	// Index is the page with every set, Sets the pages of each set.
+	Index     string
+	Sets      []Link
+	Charts    []Chart
+	Generated string
+}
+
+var page = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+	"sub": func(a, b int) int { return a - b },
+}).Parse(pageTemplate))
+
+// Write renders the page.
+func Write(w io.Writer, p Page) error {
+	return page.Execute(w, p)
+}
@@ -0,0 +1,131 @@
+package ghclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+)
+
+// CachingRoundTripper keeps GET responses on disk and revalidates them with
+// If-None-Match / If-Modified-Since. A 304 from GitHub does not count against
+// the rate limit, so unchanged searches and listings become almost free.
+type CachingRoundTripper struct {
+	Proxied http.RoundTripper
+	Dir     string
+}
+
+type cacheEntry struct {
+	Status     int         `json:"status"`
+	StatusText string      `json:"status_text"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// cacheKey identifies a response. Credentials and media type are part of the
+// key since they change what GitHub returns; only their hash is stored.
+func (c CachingRoundTripper) cacheKey(req *http.Request) string {
+	h := sha256.New()
+	io.WriteString(h, req.URL.String())
+	io.WriteString(h, "\n"+req.Header.Get("Accept"))
+	io.WriteString(h, "\n"+req.Header.Get("Authorization"))
+	return filepath.Join(c.Dir, hex.EncodeToString(h.Sum(nil))+".json")
+}
+
+func (c CachingRoundTripper) load(path string) *cacheEntry {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil
+	}
+	return &e
+}
+
+func (c CachingRoundTripper) store(path string, e *cacheEntry) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        e.StatusText,
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func (c CachingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return c.Proxied.RoundTrip(req)
+	}
+
+	path := c.cacheKey(req)
+	cached := c.load(path)
+
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	res, err := c.Proxied.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		res.Body.Close()
+		// Rate limit headers of the 304 are the current ones.
+		for k, v := range res.Header {
+			cached.Header[k] = v
+		}
+		logging.From(req.Context()).Debug("served from cache", "url", req.URL.String())
+		return cached.response(req), nil
+	}
+
+	if res.StatusCode != http.StatusOK || (res.Header.Get("ETag") == "" && res.Header.Get("Last-Modified") == "") {
+		return res, nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &cacheEntry{Status: res.StatusCode, StatusText: res.Status, Header: res.Header, Body: body}
+	if err := c.store(path, e); err != nil {
+		logging.From(req.Context()).Warn("failed to cache response", "url", req.URL.String(), "err", err)
+	}
+
+	return e.response(req), nil
+}
@@ -0,0 +1,83 @@
+package ghclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestCachingRoundTripper(t *testing.T) {
+	var conditional []string
+	etag := `"v1"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		switch r.URL.Path {
+		case "/etag":
+			w.Header().Set("X-RateLimit-Remaining", "10")
+			if r.Header.Get("If-None-Match") == etag {
+				w.Header().Set("X-RateLimit-Remaining", "9")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			io.WriteString(w, "body "+etag)
+		case "/plain":
+			io.WriteString(w, "plain")
+		case "/missing":
+			w.Header().Set("ETag", `"404"`)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	client := &http.Client{Transport: CachingRoundTripper{Proxied: http.DefaultTransport, Dir: dir}}
+	get := func(path, auth string) (int, string, http.Header) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(b), res.Header
+	}
+
+	for _, tc := range []struct {
+		name, path, auth string
+		status           int
+		body             string
+		sent             string
+	}{
+		{"first fetch", "/etag", "a", 200, `body "v1"`, ""},
+		{"revalidated", "/etag", "a", 200, `body "v1"`, `"v1"`},
+		{"other credentials", "/etag", "b", 200, `body "v1"`, ""},
+		{"no validator", "/plain", "a", 200, "plain", ""},
+		{"no validator again", "/plain", "a", 200, "plain", ""},
+		{"error", "/missing", "a", 404, "404 page not found\n", ""},
+		{"error again", "/missing", "a", 404, "404 page not found\n", ""},
+	} {
+		conditional = nil
+		status, body, header := get(tc.path, tc.auth)
+		if status != tc.status || body != tc.body {
+			t.Errorf("%s: %d %q, want %d %q", tc.name, status, body, tc.status, tc.body)
+		}
+		if len(conditional) != 1 || conditional[0] != tc.sent {
+			t.Errorf("%s: sent If-None-Match %q, want %q", tc.name, conditional, tc.sent)
+		}
+		if tc.name == "revalidated" && header.Get("X-RateLimit-Remaining") != "9" {
+			t.Errorf("%s: rate limit %q of the cached response, want the 304's 9", tc.name, header.Get("X-RateLimit-Remaining"))
+		}
+	}
+
+	// /etag once per credentials; nothing else is cached
+	if files, _ := os.ReadDir(dir); len(files) != 2 {
+		t.Errorf("%d cached responses, want 2", len(files))
+	}
+}
@@ -0,0 +1,92 @@
+package ghclient
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const searchAPI = "https://api.github.com/search/issues"
+
+// Count returns the number of issues and PRs matching the query.
+func Count(query string) (int, error) {
+	q := url.Values{}
+	q.Add("q", query)
+	q.Add("per_page", "1")
+
+	var result struct {
+		TotalCount int `json:"total_count"`
+	}
+	if _, err := Get(searchAPI+"?"+q.Encode(), &result); err != nil {
+		return -1, err
+	}
+	return result.TotalCount, nil
+}
+
+// Parallelism is the number of queries run at the same time. Override with
+// QUERY_PARALLELISM.
+func Parallelism() int {
+	if n, err := strconv.Atoi(os.Getenv("QUERY_PARALLELISM")); err == nil && n > 0 {
+		return n
+	}
+	return 4
+}
+
+// each runs fn for every index below n on a bounded pool of workers.
+func each(n int, fn func(i int)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < Parallelism(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// countREST runs the search of every query on a bounded pool of workers.
+// Counts keep the query order. A failed query gets its error in errs
+// instead of failing the whole snapshot.
+func countREST(queries []string) (counts []int, errs []error) {
+	counts = make([]int, len(queries))
+	errs = make([]error, len(queries))
+
+	each(len(queries), func(i int) {
+		var count int
+		err := WithRetry(queries[i], func() (err error) {
+			count, err = Count(queries[i])
+			return err
+		})
+		if err != nil {
+			err = fmt.Errorf("error for query %s: %w", queries[i], err)
+		}
+		counts[i], errs[i] = count, err
+	})
+
+	return counts, errs
+}
+
+// AllFailed returns an error when no query could be counted, so that a row
+// of empty cells is never written.
+func AllFailed(errs []error) error {
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("all %d columns failed, first error: %v", len(errs), errs[0])
+}
@@ -0,0 +1,52 @@
+// Package ghclient is how every command talks to GitHub: the token from
+// ACCESS_TOKEN and a transport that logs, measures, caches and rate limits
+// the calls.
+package ghclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/metrics"
+	"github.com/google/go-github/v40/github"
+	"golang.org/x/oauth2"
+)
+
+// NewTransport builds the transport used for GitHub calls. Set
+// HTTP_CACHE_DIR to cache responses across runs.
+func NewTransport() http.RoundTripper {
+	var t http.RoundTripper = newRateLimitRoundTripper(metrics.RoundTripper{Proxied: http.DefaultTransport})
+	if dir := os.Getenv("HTTP_CACHE_DIR"); dir != "" {
+		t = CachingRoundTripper{Proxied: t, Dir: dir}
+	}
+	return logging.RoundTripper{Proxied: t}
+}
+
+// HTTPClient is the client for plain REST and GraphQL calls of a run; it
+// shares one search rate limit budget.
+var HTTPClient = &http.Client{
+	Transport: NewTransport(),
+}
+
+// Token is the GitHub token in ACCESS_TOKEN, empty when it is not set.
+func Token() string {
+	return strings.TrimSpace(os.Getenv("ACCESS_TOKEN"))
+}
+
+// New returns a go-github client authenticated with the token, sending its
+// requests through HTTPClient.
+func New(ctx context.Context, token string) *github.Client {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, HTTPClient)
+	return github.NewClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})))
+}
+
+// SearchURL returns the GitHub search page of the query.
+func SearchURL(query string) string {
+	q := url.Values{}
+	q.Add("q", strings.TrimSpace(query))
+	return "https://github.com/issues?" + q.Encode()
+}
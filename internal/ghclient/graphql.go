@@ -0,0 +1,136 @@
+package ghclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+)
+
+// GraphQL counts several searches in one request using aliased fields:
+//
+//	query($q0: String!, $q1: String!) {
+//	  c0: search(type: ISSUE, query: $q0, first: 0) { issueCount }
+//	  c1: search(type: ISSUE, query: $q1, first: 0) { issueCount }
+//	}
+//
+// https://docs.github.com/en/graphql/reference/queries#search
+
+// graphqlBatchSize limits the number of searches packed into one request.
+const graphqlBatchSize = 20
+
+type graphqlRequest struct {
+	Query     string            `json:"query"`
+	Variables map[string]string `json:"variables"`
+}
+
+type graphqlResponse struct {
+	Data map[string]*struct {
+		IssueCount int `json:"issueCount"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func getGraphQLCounts(token string, queries []string) (map[int]int, error) {
+	var vars, fields []string
+	req := graphqlRequest{Variables: map[string]string{}}
+	for i, q := range queries {
+		vars = append(vars, fmt.Sprintf("$q%d: String!", i))
+		fields = append(fields, fmt.Sprintf("c%d: search(type: ISSUE, query: $q%d, first: 0) { issueCount }", i, i))
+		req.Variables[fmt.Sprintf("q%d", i)] = q
+	}
+	req.Query = fmt.Sprintf("query(%s) {\n%s\n}", strings.Join(vars, ", "), strings.Join(fields, "\n"))
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, "https://api.github.com/graphql", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "bearer "+token)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query GraphQL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{resp.StatusCode, string(b), resp.Header}
+	}
+
+	var result graphqlResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse GraphQL response: %v", err)
+	}
+
+	for _, e := range result.Errors {
+		logging.Logger.Warn("GraphQL error", "message", e.Message)
+	}
+
+	counts := map[int]int{}
+	for i := range queries {
+		if c := result.Data[fmt.Sprintf("c%d", i)]; c != nil {
+			counts[i] = c.IssueCount
+		}
+	}
+	return counts, nil
+}
+
+// Counts returns the count of every query. With ACCESS_TOKEN set all
+// queries are counted in one or two GraphQL requests; queries GraphQL could
+// not answer, or all of them without a token, fall back to REST searches.
+// errs holds the error of each query that could not be counted.
+func Counts(queries []string) (counts []int, errs []error) {
+	counts = make([]int, len(queries))
+	errs = make([]error, len(queries))
+	done := make([]bool, len(queries))
+
+	token := Token()
+	if token != "" {
+		for start := 0; start < len(queries); start += graphqlBatchSize {
+			end := min(start+graphqlBatchSize, len(queries))
+
+			var batch map[int]int
+			err := WithRetry("graphql", func() (err error) {
+				batch, err = getGraphQLCounts(token, queries[start:end])
+				return err
+			})
+			if err != nil {
+				logging.Logger.Warn("GraphQL counts failed, falling back to REST", "err", err)
+				continue
+			}
+			for i, count := range batch {
+				counts[start+i] = count
+				done[start+i] = true
+			}
+		}
+	}
+
+	var rest []string
+	var restIndex []int
+	for i, q := range queries {
+		if !done[i] {
+			rest = append(rest, q)
+			restIndex = append(restIndex, i)
+		}
+	}
+
+	restCounts, restErrs := countREST(rest)
+	for j, i := range restIndex {
+		counts[i], errs[i] = restCounts[j], restErrs[j]
+	}
+
+	return counts, errs
+}
@@ -0,0 +1,106 @@
+package ghclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// redirect sends every request to the test server instead of GitHub.
+type redirect struct{ to *url.URL }
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = r.to.Scheme, r.to.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// fakeGitHub answers the GitHub calls of HTTPClient with h for the rest of
+// the test.
+func fakeGitHub(t *testing.T, h http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	u, _ := url.Parse(srv.URL)
+	old := HTTPClient
+	HTTPClient = &http.Client{Transport: redirect{u}}
+	t.Cleanup(func() {
+		HTTPClient = old
+		srv.Close()
+	})
+}
+
+func TestCountsBatchesGraphQL(t *testing.T) {
+	t.Setenv("ACCESS_TOKEN", "token")
+	var mu sync.Mutex
+	var batches []int
+	fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			return
+		}
+		var req graphqlRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		batches = append(batches, len(req.Variables))
+		mu.Unlock()
+
+		data := map[string]interface{}{}
+		for i := 0; i < len(req.Variables); i++ {
+			field := fmt.Sprintf("c%d: search(type: ISSUE, query: $q%d, first: 0) { issueCount }", i, i)
+			if !strings.Contains(req.Query, field) {
+				t.Errorf("query %q does not have %q", req.Query, field)
+			}
+			// the count of query "n" is n
+			var n int
+			fmt.Sscan(req.Variables[fmt.Sprintf("q%d", i)], &n)
+			data[fmt.Sprintf("c%d", i)] = map[string]int{"issueCount": n}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	})
+
+	var queries []string
+	for i := 0; i < 25; i++ {
+		queries = append(queries, fmt.Sprint(i))
+	}
+	counts, errs := Counts(queries)
+	for i := range queries {
+		if errs[i] != nil || counts[i] != i {
+			t.Errorf("query %d: count %d, error %v", i, counts[i], errs[i])
+		}
+	}
+	if fmt.Sprint(batches) != "[20 5]" {
+		t.Errorf("batches of %v, want [20 5]", batches)
+	}
+}
+
+func TestCountsFallsBackToREST(t *testing.T) {
+	t.Setenv("ACCESS_TOKEN", "token")
+	t.Setenv("QUERY_RETRIES", "0")
+	fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/graphql":
+			// GraphQL answers only the first query
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data":   map[string]interface{}{"c0": map[string]int{"issueCount": 7}, "c1": nil},
+				"errors": []map[string]string{{"message": "timeout"}},
+			})
+		case "/search/issues":
+			if q := r.URL.Query().Get("q"); q != "b" {
+				t.Errorf("REST search of %q, want only b", q)
+			}
+			fmt.Fprint(w, `{"total_count": 3}`)
+		}
+	})
+
+	counts, errs := Counts([]string{"a", "b"})
+	if errs[0] != nil || errs[1] != nil || counts[0] != 7 || counts[1] != 3 {
+		t.Errorf("Counts = %v, %v; want [7 3]", counts, errs)
+	}
+}
@@ -0,0 +1,84 @@
+package ghclient
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+)
+
+// RateLimitRoundTripper keeps concurrent searches within the search rate
+// limit. It learns the budget from the X-RateLimit-* headers GitHub returns
+// and holds requests back until the window resets once it is spent.
+type RateLimitRoundTripper struct {
+	Proxied http.RoundTripper
+	budget  *rateBudget
+}
+
+type rateBudget struct {
+	mu        sync.Mutex
+	remaining int
+	reset     time.Time
+	inflight  int
+}
+
+func newRateLimitRoundTripper(proxied http.RoundTripper) RateLimitRoundTripper {
+	return RateLimitRoundTripper{Proxied: proxied, budget: &rateBudget{}}
+}
+
+func (b *rateBudget) acquire() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.remaining <= 0 {
+		if wait := time.Until(b.reset); wait > 0 {
+			logging.Logger.Debug("waiting for the search rate limit to reset", "wait", wait.String())
+			b.mu.Unlock()
+			time.Sleep(wait)
+			b.mu.Lock()
+			continue
+		}
+		// The window is unknown or has reset: let a single request
+		// through to learn the new budget.
+		b.remaining = 1
+		b.reset = time.Now().Add(time.Second)
+	}
+	b.remaining--
+	b.inflight++
+}
+
+func (b *rateBudget) release(h http.Header) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.inflight--
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	// Requests still in flight will spend from what GitHub reports.
+	b.remaining = remaining - b.inflight
+	b.reset = time.Unix(reset, 0)
+}
+
+func (rt RateLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(req.URL.Path, "/search/") {
+		return rt.Proxied.RoundTrip(req)
+	}
+
+	rt.budget.acquire()
+	res, err := rt.Proxied.RoundTrip(req)
+	if err != nil {
+		rt.budget.release(http.Header{})
+		return res, err
+	}
+	rt.budget.release(res.Header)
+	return res, nil
+}
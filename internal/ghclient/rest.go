@@ -0,0 +1,79 @@
+package ghclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+// StatusError is returned for a GitHub response other than 2xx. Header
+// tells rate limited requests when to try again.
+type StatusError struct {
+	StatusCode int
+	Body       string
+	Header     http.Header
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code is not 200: %v, %v", e.StatusCode, e.Body)
+}
+
+var linkNext = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// Do sends a request to the GitHub API, authenticated when ACCESS_TOKEN is
+// set, decodes the response into v (when not nil) and returns the URL of
+// the next page, if any.
+func Do(method, rawURL string, body interface{}, v interface{}) (string, error) {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return "", err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, rawURL, reader)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to %s %s: %w", method, rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(resp.Body)
+		return "", &StatusError{resp.StatusCode, string(b), resp.Header}
+	}
+
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return "", fmt.Errorf("failed to parse JSON: %v", err)
+		}
+	}
+
+	var next string
+	if m := linkNext.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+		next = m[1]
+	}
+	return next, nil
+}
+
+// Get decodes a GitHub API response into v and returns the URL of the next
+// page, if any.
+func Get(rawURL string, v interface{}) (string, error) {
+	return Do(http.MethodGet, rawURL, nil, v)
+}
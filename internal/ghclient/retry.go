@@ -0,0 +1,95 @@
+package ghclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+)
+
+// retryable tells transient failures (network errors, rate limiting, server
+// errors) from requests that will fail the same way again, such as bad
+// queries, missing permissions or responses that do not parse.
+func retryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		if se.StatusCode == http.StatusForbidden {
+			_, limited := rateLimitWait(err)
+			return limited
+		}
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var ue *url.Error
+	return errors.As(err, &ue)
+}
+
+// maxRateLimitWait is the longest a query waits for a rate limit to reset
+// rather than fail; the search limit resets every minute, the core limit
+// only every hour.
+const maxRateLimitWait = 2 * time.Minute
+
+// rateLimitWait returns how long to wait for the rate limit that rejected
+// the request to reset, from the Retry-After or X-RateLimit-Reset headers.
+func rateLimitWait(err error) (time.Duration, bool) {
+	var se *StatusError
+	if !errors.As(err, &se) || (se.StatusCode != 403 && se.StatusCode != 429) {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(se.Header.Get("Retry-After")); err == nil {
+		return time.Duration(s) * time.Second, true
+	}
+	if se.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(se.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			// a second more for clock skew
+			return time.Until(time.Unix(reset, 0)) + time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// queryRetries is the number of retries of a failed query. Override with
+// QUERY_RETRIES.
+func queryRetries() int {
+	if n, err := strconv.Atoi(os.Getenv("QUERY_RETRIES")); err == nil && n >= 0 {
+		return n
+	}
+	return 3
+}
+
+// retryBaseDelay is the backoff before the first retry; it doubles after
+// every attempt.
+var retryBaseDelay = 2 * time.Second
+
+// WithRetry calls fn until it succeeds or the retries are spent, sleeping a
+// random duration up to an exponentially growing limit between attempts
+// ("full jitter") so concurrent workers do not retry in lockstep. A rate
+// limited attempt sleeps until the limit resets instead.
+func WithRetry(what string, fn func() error) error {
+	retries := queryRetries()
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries || !retryable(err) {
+			return err
+		}
+
+		delay := time.Duration(rand.Int63n(int64(retryBaseDelay << attempt)))
+		if wait, ok := rateLimitWait(err); ok {
+			if wait > maxRateLimitWait {
+				return fmt.Errorf("rate limited for %s: %w", wait.Round(time.Second), err)
+			}
+			delay = max(wait, 0)
+		}
+		logging.Logger.Warn("retrying", "query", what, "attempt", attempt+1, "delay", delay.String(), "err", err)
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,94 @@
+package ghclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func statusError(code int, header ...string) error {
+	h := http.Header{}
+	for i := 0; i+1 < len(header); i += 2 {
+		h.Set(header[i], header[i+1])
+	}
+	return fmt.Errorf("error for query q: %w", &StatusError{StatusCode: code, Header: h})
+}
+
+func TestRetryable(t *testing.T) {
+	reset := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+	network := &url.Error{Op: "Get", URL: "https://api.github.com/search/issues", Err: errors.New("connection reset by peer")}
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"network error", fmt.Errorf("failed to GET: %w", network), true},
+		{"server error", statusError(502), true},
+		{"too many requests", statusError(429), true},
+		{"rate limited", statusError(403, "X-RateLimit-Remaining", "0", "X-RateLimit-Reset", reset), true},
+		{"secondary rate limit", statusError(403, "Retry-After", "30"), true},
+		{"no permission", statusError(403), false},
+		{"bad query", statusError(422), false},
+		{"not found", statusError(404), false},
+		{"canceled", fmt.Errorf("failed to GET: %w", &url.Error{Op: "Get", URL: "u", Err: context.Canceled}), false},
+		{"bad JSON", errors.New("failed to parse JSON: unexpected EOF"), false},
+	} {
+		if got := retryable(tc.err); got != tc.want {
+			t.Errorf("%s: retryable = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	in := func(d time.Duration) string { return strconv.FormatInt(time.Now().Add(d).Unix(), 10) }
+
+	for _, tc := range []struct {
+		name     string
+		err      error
+		min, max time.Duration
+		ok       bool
+	}{
+		{"retry after", statusError(403, "Retry-After", "30"), 30 * time.Second, 30 * time.Second, true},
+		{"retry after 429", statusError(429, "Retry-After", "5"), 5 * time.Second, 5 * time.Second, true},
+		{"limit spent", statusError(403, "X-RateLimit-Remaining", "0", "X-RateLimit-Reset", in(time.Minute)), 59 * time.Second, 62 * time.Second, true},
+		{"limit left", statusError(403, "X-RateLimit-Remaining", "10", "X-RateLimit-Reset", in(time.Minute)), 0, 0, false},
+		{"no headers", statusError(403), 0, 0, false},
+		{"server error", statusError(500, "Retry-After", "30"), 0, 0, false},
+		{"not a status", errors.New("boom"), 0, 0, false},
+	} {
+		wait, ok := rateLimitWait(tc.err)
+		if ok != tc.ok || wait < tc.min || wait > tc.max {
+			t.Errorf("%s: rateLimitWait = %v, %v; want %v..%v, %v", tc.name, wait, ok, tc.min, tc.max, tc.ok)
+		}
+	}
+}
+
+func TestWithRetryStopsOnPermanentErrors(t *testing.T) {
+	defer func(d time.Duration) { retryBaseDelay = d }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+	t.Setenv("QUERY_RETRIES", "3")
+
+	for _, tc := range []struct {
+		name  string
+		err   error
+		calls int
+	}{
+		{"server error", statusError(502), 4},
+		{"no permission", statusError(403), 1},
+	} {
+		calls := 0
+		err := WithRetry("q", func() error {
+			calls++
+			return tc.err
+		})
+		if err == nil || calls != tc.calls {
+			t.Errorf("%s: %d calls, error %v; want %d calls and the error", tc.name, calls, err, tc.calls)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package ghclient
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+)
+
+// searchLimit is the number of results the search API returns at most.
+const searchLimit = 1000
+
+// Search returns every issue and PR matching the query, decoded into T, up
+// to the 1000 results the search API returns, and how many match in all.
+// Every page is retried like the counts.
+func Search[T any](query string) ([]T, int, error) {
+	return search[T](query, false)
+}
+
+// search is Search; with whole it stops after the first page when more items
+// match than the search API returns, and returns no items then.
+func search[T any](query string, whole bool) ([]T, int, error) {
+	q := url.Values{}
+	q.Add("q", query)
+	q.Add("per_page", "100")
+
+	var items []T
+	total := 0
+	next := searchAPI + "?" + q.Encode()
+	for next != "" {
+		var page struct {
+			TotalCount int `json:"total_count"`
+			Items      []T `json:"items"`
+		}
+		current := next
+		err := WithRetry(query, func() (err error) {
+			next, err = Get(current, &page)
+			return err
+		})
+		if err != nil {
+			return nil, 0, fmt.Errorf("error for query %s: %w", query, err)
+		}
+		total = page.TotalCount
+		if whole && total > searchLimit {
+			return nil, total, nil
+		}
+		items = append(items, page.Items...)
+	}
+	return items, total, nil
+}
+
+// CountItems returns the count of every query like Counts and, with list,
+// the issues and PRs matching it as owner/repo#number. Listed queries are
+// counted by the same search that lists them, so the items always add up
+// to the count. Queries matching more items than the search API returns
+// keep their count with nil items, as do all of them without list.
+func CountItems(queries []string, list bool) (counts []int, items [][]string, errs []error) {
+	items = make([][]string, len(queries))
+	if !list {
+		counts, errs = Counts(queries)
+		return counts, items, errs
+	}
+
+	counts = make([]int, len(queries))
+	errs = make([]error, len(queries))
+	each(len(queries), func(i int) {
+		found, total, err := search[struct {
+			Number        int    `json:"number"`
+			RepositoryURL string `json:"repository_url"`
+		}](queries[i], true)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		counts[i] = total
+		if total > searchLimit {
+			logging.Logger.Warn("too many items to list", "query", queries[i], "count", total, "limit", searchLimit)
+			return
+		}
+		items[i] = []string{}
+		for _, f := range found {
+			repo := strings.TrimPrefix(f.RepositoryURL, "https://api.github.com/repos/")
+			items[i] = append(items[i], fmt.Sprintf("%s#%d", repo, f.Number))
+		}
+	})
+	return counts, items, errs
+}
+
+// ItemURL returns the GitHub page of an owner/repo#number item; issue links
+// of PRs redirect to the PR.
+func ItemURL(ref string) string {
+	return "https://github.com/" + strings.Replace(ref, "#", "/issues/", 1)
+}
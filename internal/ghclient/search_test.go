@@ -0,0 +1,56 @@
+package ghclient
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCountItems(t *testing.T) {
+	var mu sync.Mutex
+	pages := map[string]int{}
+	fakeGitHub(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		mu.Lock()
+		pages[q]++
+		mu.Unlock()
+		switch q {
+		case "small":
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("Link", fmt.Sprintf(`<http://%s/search/issues?q=small&per_page=100&page=2>; rel="next"`, r.Host))
+				fmt.Fprint(w, `{"total_count": 2, "items": [{"number": 1, "repository_url": "https://api.github.com/repos/o/r"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"total_count": 2, "items": [{"number": 2, "repository_url": "https://api.github.com/repos/o/r"}]}`)
+		case "large":
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/search/issues?q=large&per_page=100&page=2>; rel="next"`, r.Host))
+			fmt.Fprint(w, `{"total_count": 1500, "items": [{"number": 1, "repository_url": "https://api.github.com/repos/o/r"}]}`)
+		case "none":
+			fmt.Fprint(w, `{"total_count": 0, "items": []}`)
+		}
+	})
+
+	counts, items, errs := CountItems([]string{"small", "large", "none"}, true)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+	}
+	if fmt.Sprint(counts) != "[2 1500 0]" {
+		t.Errorf("counts %v, want [2 1500 0]", counts)
+	}
+	if got := strings.Join(items[0], ","); got != "o/r#1,o/r#2" {
+		t.Errorf("small items %q", got)
+	}
+	if items[1] != nil {
+		t.Errorf("large items %q, want none", items[1])
+	}
+	if items[2] == nil || len(items[2]) != 0 {
+		t.Errorf("items of an empty column %#v, want listed and empty", items[2])
+	}
+	if pages["large"] != 1 {
+		t.Errorf("read %d pages of a column over the limit, want 1", pages["large"])
+	}
+}
@@ -0,0 +1,140 @@
+// Package logging is the structured JSON logging of every command.
+// LOG_LEVEL selects verbosity (debug, info, warn, error). Secrets are
+// redacted by attribute name and by value pattern so that tokens never end
+// up in the logs, whatever attribute carries them.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Logger is the process logger. Every line of a run carries the same
+// run_id; the server tags each request with a request_id on top.
+var Logger = newLogger().With("run_id", newRequestID())
+
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"access_token":  true,
+	"token":         true,
+	"password":      true,
+	"secret":        true,
+	"cookie":        true,
+	"set-cookie":    true,
+}
+
+var secretPattern = regexp.MustCompile(`(gh[pousr]_[A-Za-z0-9]{16,}|github_pat_[A-Za-z0-9_]{16,}|(?i:bearer|token)\s+[A-Za-z0-9_.\-]{16,})`)
+
+const redacted = "[REDACTED]"
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	var s string
+	switch v := a.Value.Any().(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	default:
+		return a
+	}
+	if secretPattern.MatchString(s) {
+		return slog.String(a.Key, secretPattern.ReplaceAllString(s, redacted))
+	}
+	return a
+}
+
+func newLogger() *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		level = slog.LevelInfo
+	}
+	return slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}))
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type loggerKey struct{}
+
+// From returns the request scoped logger stored by WithRequestID, or Logger.
+func From(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return Logger
+}
+
+// NewContext returns a context carrying the logger, for work that outlives
+// the request context.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// WithRequestID tags every log line of an incoming request with a request
+// ID, taken from X-Request-Id when the caller provides one.
+func WithRequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-Id")
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-Id", id)
+
+		l := Logger.With("request_id", id)
+		l.Info("handling request", "method", r.Method, "path", r.URL.Path)
+		next(w, r.WithContext(NewContext(r.Context(), l)))
+	}
+}
+
+// loggedHeader logs headers as a group so redaction applies to each of them.
+type loggedHeader http.Header
+
+func (h loggedHeader) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(h))
+	for k, v := range h {
+		attrs = append(attrs, slog.String(k, strings.Join(v, ", ")))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// RoundTripper logs every request with the logger of its context.
+type RoundTripper struct {
+	Proxied http.RoundTripper
+}
+
+func (lrt RoundTripper) RoundTrip(req *http.Request) (res *http.Response, e error) {
+	l := From(req.Context())
+	l.Debug("sending request", "method", req.Method, "url", req.URL.String(), "headers", loggedHeader(req.Header))
+
+	start := time.Now()
+	res, e = lrt.Proxied.RoundTrip(req)
+
+	if e != nil {
+		l.Error("request failed", "method", req.Method, "url", req.URL.String(), "err", e)
+	} else {
+		l.Info("received response",
+			"method", req.Method,
+			"url", req.URL.String(),
+			"status", res.StatusCode,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"github_request_id", res.Header.Get("X-GitHub-Request-Id"))
+	}
+
+	return
+}
@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	token := "ghp_" + strings.Repeat("a", 36)
+
+	for _, tc := range []struct {
+		name    string
+		attr    slog.Attr
+		want    string
+		secrets []string
+	}{
+		{"sensitive key", slog.String("Authorization", "anything"), `"Authorization":"[REDACTED]"`, []string{"anything"}},
+		{"token key", slog.Int("token", 987654321), `"token":"[REDACTED]"`, []string{"987654321"}},
+		{"token value", slog.String("url", "https://x/?t="+token), `"url":"https://x/?t=[REDACTED]"`, []string{token}},
+		{"fine grained token", slog.String("msg2", "github_pat_"+strings.Repeat("B", 30)), `"msg2":"[REDACTED]"`, []string{"github_pat_"}},
+		{"bearer", slog.String("h", "Bearer abcdefghijklmnopqrstuvwxyz"), `"h":"[REDACTED]"`, []string{"abcdefghijklmnop"}},
+		{"in an error", slog.Any("err", errors.New("bad credentials for "+token)), `"err":"bad credentials for [REDACTED]"`, []string{token}},
+		{"in a header group", slog.Any("headers", loggedHeader(http.Header{"Authorization": {"token " + token}, "Accept": {"application/json"}})), `"Accept":"application/json"`, []string{token}},
+		{"short values kept", slog.String("text", "token abc"), `"text":"token abc"`, nil},
+		{"other values kept", slog.Int("count", 3), `"count":3`, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+			slog.New(slog.NewJSONHandler(&b, &slog.HandlerOptions{ReplaceAttr: redact})).Info("m", tc.attr)
+			got := b.String()
+			if !strings.Contains(got, tc.want) {
+				t.Errorf("logged %s, want %s", got, tc.want)
+			}
+			for _, s := range tc.secrets {
+				if strings.Contains(got, s) {
+					t.Errorf("logged %s with the secret %s", got, s)
+				}
+			}
+		})
+	}
+}
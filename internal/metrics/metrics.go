@@ -0,0 +1,307 @@
+// Package metrics is a minimal Prometheus registry. Only what is needed to
+// expose counters, gauges and histograms in the text exposition format:
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+//
+// The server serves them with Handler; the jobs push or write them at the
+// end of the run with Flush.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+)
+
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+type metricFamily struct {
+	help       string
+	metricType string
+	values     map[string]float64
+	histograms map[string]*histogram
+}
+
+type registry struct {
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+var metrics = newRegistry()
+
+// Add adds v to the counter with the labels, given as name, value pairs.
+func Add(name string, v float64, labels ...string) {
+	metrics.add(name, v, labels...)
+}
+
+// Set sets the gauge with the labels.
+func Set(name string, v float64, labels ...string) {
+	metrics.set(name, v, labels...)
+}
+
+// Observe adds v to the histogram with the labels.
+func Observe(name string, v float64, labels ...string) {
+	metrics.observe(name, v, labels...)
+}
+
+func newRegistry() *registry {
+	r := &registry{families: map[string]*metricFamily{}}
+
+	r.describe("github_api_requests_total", "counter", "GitHub API requests by endpoint, method and status code.")
+	r.describe("github_api_request_duration_seconds", "histogram", "GitHub API request latency by endpoint.")
+	r.describe("github_rate_limit_remaining", "gauge", "Requests remaining in the current GitHub rate limit window.")
+	r.describe("dashboard_column_count", "gauge", "Latest count for a dashboard column.")
+	r.describe("triage_cards_created_total", "counter", "Project cards created by triage rule.")
+	r.describe("triage_prs_routed_total", "counter", "PRs routed to a subproject by OWNERS files.")
+	r.describe("dashboard_count_cache_total", "counter", "Dashboard count lookups by column set and cache result.")
+
+	return r
+}
+
+func (r *registry) describe(name, metricType, help string) {
+	r.families[name] = &metricFamily{
+		help:       help,
+		metricType: metricType,
+		values:     map[string]float64{},
+		histograms: map[string]*histogram{},
+	}
+}
+
+// labelString formats name/value pairs as {name="value",...}.
+func labelString(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "%s=%s", labels[i], strconv.Quote(labels[i+1]))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func (r *registry) add(name string, v float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.families[name].values[labelString(labels)] += v
+}
+
+func (r *registry) set(name string, v float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.families[name].values[labelString(labels)] = v
+}
+
+func (r *registry) observe(name string, v float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := labelString(labels)
+	h := r.families[name].histograms[key]
+	if h == nil {
+		h = &histogram{buckets: make([]uint64, len(latencyBuckets))}
+		r.families[name].histograms[key] = h
+	}
+	for i, le := range latencyBuckets {
+		if v <= le {
+			h.buckets[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// withLabel inserts an extra label into a formatted label string.
+func withLabel(key, name, value string) string {
+	l := fmt.Sprintf("%s=%s", name, strconv.Quote(value))
+	if key == "" {
+		return "{" + l + "}"
+	}
+	return key[:len(key)-1] + "," + l + "}"
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]float64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*histogram:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*metricFamily:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *registry) write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range sortedKeys(r.families) {
+		f := r.families[name]
+		if len(f.values) == 0 && len(f.histograms) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n", name, f.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, f.metricType)
+		for _, key := range sortedKeys(f.values) {
+			fmt.Fprintf(w, "%s%s %s\n", name, key, formatFloat(f.values[key]))
+		}
+		for _, key := range sortedKeys(f.histograms) {
+			h := f.histograms[key]
+			for i, le := range latencyBuckets {
+				fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(key, "le", formatFloat(le)), h.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(key, "le", "+Inf"), h.count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", name, key, formatFloat(h.sum))
+			_, err := fmt.Fprintf(w, "%s_count%s %d\n", name, key, h.count)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pushMetrics replaces the metrics of the job on a Prometheus Pushgateway.
+func pushMetrics(gateway, job string) error {
+	var b bytes.Buffer
+	metrics.write(&b)
+
+	req, err := http.NewRequest(http.MethodPut, strings.TrimRight(gateway, "/")+"/metrics/job/"+url.PathEscape(job), &b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("pushgateway returned %v: %v", resp.StatusCode, string(b))
+	}
+	return nil
+}
+
+// writeMetricsFile writes metrics for the node_exporter textfile collector.
+// The file is replaced atomically so the collector never sees a partial file.
+func writeMetricsFile(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = metrics.write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Handler serves the metrics to Prometheus.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics.write(w)
+}
+
+// Flush exports metrics collected during the run. Set METRICS_PUSHGATEWAY to
+// push them or METRICS_TEXTFILE to write them to a file.
+func Flush(job string) {
+	if gateway := os.Getenv("METRICS_PUSHGATEWAY"); gateway != "" {
+		if err := pushMetrics(gateway, job); err != nil {
+			logging.Logger.Error("failed to push metrics", "err", err)
+		}
+	}
+	if path := os.Getenv("METRICS_TEXTFILE"); path != "" {
+		if err := writeMetricsFile(path); err != nil {
+			logging.Logger.Error("failed to write metrics", "path", path, "err", err)
+		}
+	}
+}
+
+// apiEndpoint turns a request path into a low cardinality label by
+// replacing numeric IDs: /projects/columns/123/cards -> /projects/columns/:id/cards
+func apiEndpoint(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// RoundTripper records GitHub API call counts, latency and the rate limit
+// reported in the response headers.
+type RoundTripper struct {
+	Proxied http.RoundTripper
+}
+
+func (mrt RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := apiEndpoint(req.URL.Path)
+	if req.URL.Host != "api.github.com" {
+		// e.g. raw.githubusercontent.com, where every file is a path
+		endpoint = req.URL.Host
+	}
+	start := time.Now()
+
+	res, err := mrt.Proxied.RoundTrip(req)
+
+	metrics.observe("github_api_request_duration_seconds", time.Since(start).Seconds(), "endpoint", endpoint)
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(res.StatusCode)
+
+		if remaining, perr := strconv.Atoi(res.Header.Get("X-RateLimit-Remaining")); perr == nil {
+			resource := res.Header.Get("X-RateLimit-Resource")
+			if resource == "" {
+				resource = "core"
+			}
+			metrics.set("github_rate_limit_remaining", float64(remaining), "resource", resource)
+		}
+	}
+	metrics.add("github_api_requests_total", 1, "endpoint", endpoint, "method", req.Method, "code", code)
+
+	return res, err
+}
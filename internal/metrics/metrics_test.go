@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := newRegistry()
+	r.add("triage_cards_created_total", 1, "rule", "b")
+	r.add("triage_cards_created_total", 2, "rule", `a "quoted"`)
+	r.add("triage_cards_created_total", 1, "rule", "b")
+	r.set("github_rate_limit_remaining", 4999, "resource", "core")
+	r.set("github_rate_limit_remaining", 29, "resource", "search")
+	r.set("github_rate_limit_remaining", 28, "resource", "search")
+	r.observe("github_api_request_duration_seconds", 0.2, "endpoint", "/search/issues")
+	r.observe("github_api_request_duration_seconds", 3, "endpoint", "/search/issues")
+	r.observe("github_api_request_duration_seconds", 20, "endpoint", "/search/issues")
+
+	var b bytes.Buffer
+	if err := r.write(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP github_api_request_duration_seconds GitHub API request latency by endpoint.
+# TYPE github_api_request_duration_seconds histogram
+github_api_request_duration_seconds_bucket{endpoint="/search/issues",le="0.05"} 0
+github_api_request_duration_seconds_bucket{endpoint="/search/issues",le="0.1"} 0
+github_api_request_duration_seconds_bucket{endpoint="/search/issues",le="0.25"} 1
+github_api_request_duration_seconds_bucket{endpoint="/search/issues",le="0.5"} 1
+github_api_request_duration_seconds_bucket{endpoint="/search/issues",le="1"} 1
+github_api_request_duration_seconds_bucket{endpoint="/search/issues",le="2.5"} 1
+github_api_request_duration_seconds_bucket{endpoint="/search/issues",le="5"} 2
+github_api_request_duration_seconds_bucket{endpoint="/search/issues",le="10"} 2
+github_api_request_duration_seconds_bucket{endpoint="/search/issues",le="+Inf"} 3
+github_api_request_duration_seconds_sum{endpoint="/search/issues"} 23.2
+github_api_request_duration_seconds_count{endpoint="/search/issues"} 3
+# HELP github_rate_limit_remaining Requests remaining in the current GitHub rate limit window.
+# TYPE github_rate_limit_remaining gauge
+github_rate_limit_remaining{resource="core"} 4999
+github_rate_limit_remaining{resource="search"} 28
+# HELP triage_cards_created_total Project cards created by triage rule.
+# TYPE triage_cards_created_total counter
+triage_cards_created_total{rule="a \"quoted\""} 2
+triage_cards_created_total{rule="b"} 2
+`
+	if b.String() != want {
+		t.Errorf("write =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestAPIEndpoint(t *testing.T) {
+	for path, want := range map[string]string{
+		"/search/issues":                "/search/issues",
+		"/projects/columns/123/cards":   "/projects/columns/:id/cards",
+		"/repos/o/r/issues/45/timeline": "/repos/o/r/issues/:id/timeline",
+		"/graphql":                      "/graphql",
+	} {
+		if got := apiEndpoint(path); got != want {
+			t.Errorf("apiEndpoint(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
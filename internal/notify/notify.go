@@ -0,0 +1,78 @@
+// Package notify posts messages to Slack compatible incoming webhooks,
+// listed comma separated in NOTIFY_WEBHOOKS as URLs or name=URL, so alert
+// rules can pick webhooks by name. The webhook URLs are secrets, so they are
+// posted with a client of their own that does not log requests.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+type Webhook struct {
+	Name string
+	URL  string
+}
+
+// Webhooks returns the webhooks in NOTIFY_WEBHOOKS.
+func Webhooks() []Webhook {
+	var hooks []Webhook
+	for _, entry := range strings.Split(os.Getenv("NOTIFY_WEBHOOKS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		var h Webhook
+		if name, u, ok := strings.Cut(entry, "="); ok && !strings.Contains(name, "/") {
+			h = Webhook{name, u}
+		} else {
+			h = Webhook{URL: entry}
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks
+}
+
+// Send posts the message, in Slack mrkdwn, to the named webhooks, or to
+// every webhook without names.
+func Send(names []string, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, h := range Webhooks() {
+		if len(names) > 0 && !contains(names, h.Name) {
+			continue
+		}
+		resp, err := webhookClient.Post(h.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			// The error has the URL in it.
+			errs = append(errs, fmt.Errorf("webhook %d %s: request failed", i, h.Name))
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			errs = append(errs, fmt.Errorf("webhook %d %s: status code %d", i, h.Name, resp.StatusCode))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
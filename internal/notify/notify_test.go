@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// hook records the messages posted to it and answers with status.
+func hook(t *testing.T, status int, got *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("bad body: %v", err)
+		}
+		*got = append(*got, body.Text)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSendSelectsNamedWebhooks(t *testing.T) {
+	var ci, release, plain []string
+	ciHook := hook(t, http.StatusOK, &ci)
+	releaseHook := hook(t, http.StatusOK, &release)
+	plainHook := hook(t, http.StatusOK, &plain)
+	t.Setenv("NOTIFY_WEBHOOKS", "ci="+ciHook.URL+", release="+releaseHook.URL+","+plainHook.URL)
+
+	if err := Send([]string{"ci"}, "to ci"); err != nil {
+		t.Fatalf("Send to ci: %v", err)
+	}
+	if err := Send(nil, "to all"); err != nil {
+		t.Fatalf("Send to all: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"ci", ci, []string{"to ci", "to all"}},
+		{"release", release, []string{"to all"}},
+		{"unnamed", plain, []string{"to all"}},
+	} {
+		if strings.Join(tc.got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("%s webhook got %q, want %q", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestSendFailsOnErrorStatus(t *testing.T) {
+	var ok, failed []string
+	okHook := hook(t, http.StatusOK, &ok)
+	failedHook := hook(t, http.StatusForbidden, &failed)
+	t.Setenv("NOTIFY_WEBHOOKS", "ok="+okHook.URL+",failed="+failedHook.URL)
+
+	err := Send(nil, "message")
+	if err == nil {
+		t.Fatal("Send succeeded with a webhook answering 403")
+	}
+	if !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("error %q does not name the webhook and status", err)
+	}
+	if len(ok) != 1 {
+		t.Errorf("the other webhook got %d messages, want 1", len(ok))
+	}
+}
+
+func TestSendKeepsURLsOutOfErrors(t *testing.T) {
+	var failed []string
+	failedHook := hook(t, http.StatusInternalServerError, &failed)
+	secret := failedHook.URL + "/services/T000/B000/secret"
+	// nothing listens on a closed server, so the request itself fails
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	unreachable := closed.URL + "/services/T111/B111/secret"
+	t.Setenv("NOTIFY_WEBHOOKS", "status="+secret+","+unreachable)
+
+	err := Send(nil, "message")
+	if err == nil {
+		t.Fatal("Send succeeded with failing webhooks")
+	}
+	for _, leaked := range []string{"secret", failedHook.URL, closed.URL} {
+		if strings.Contains(err.Error(), leaked) {
+			t.Errorf("error %q has %q in it", err, leaked)
+		}
+	}
+}
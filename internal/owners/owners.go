@@ -0,0 +1,166 @@
+// Package owners reads Kubernetes style OWNERS files from GitHub to find
+// the approvers and reviewers of a path.
+package owners
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+)
+
+// https://www.kubernetes.dev/docs/guide/owners/
+//
+// Only approvers, reviewers and OWNERS_ALIASES are read; filters are
+// flattened, so approvers of any filter count for the whole directory.
+
+// Owners is the content of an OWNERS file.
+type Owners struct {
+	Approvers []string
+	Reviewers []string
+	Labels    []string
+}
+
+// parse reads the lists of an OWNERS or OWNERS_ALIASES file. It is a
+// line based reader rather than a YAML parser: every "- item" line is added
+// to the list of the closest key above it.
+func parse(r io.Reader) map[string][]string {
+	lists := map[string][]string{}
+	key := ""
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "- "):
+			item := strings.Trim(strings.TrimSpace(line[2:]), `"'`)
+			lists[key] = append(lists[key], item)
+		case strings.HasSuffix(line, ":"):
+			key = strings.Trim(strings.TrimSuffix(line, ":"), `"'`)
+		}
+	}
+	return lists
+}
+
+// Resolver finds the nearest OWNERS file of a path in a repository,
+// fetching each file once from raw.githubusercontent.com.
+type Resolver struct {
+	Repo string
+	Ref  string
+
+	mu      sync.Mutex
+	files   map[string]*Owners
+	aliases map[string][]string
+}
+
+// NewResolver returns a resolver of the OWNERS files of repo at ref.
+func NewResolver(repo, ref string) *Resolver {
+	return &Resolver{Repo: repo, Ref: ref, files: map[string]*Owners{}}
+}
+
+func (o *Resolver) fetch(file string) (io.ReadCloser, error) {
+	url := fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s", o.Repo, o.Ref, file)
+	resp, err := ghclient.HTTPClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %v", url, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, nil
+	}
+	if resp.StatusCode != 200 {
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, &ghclient.StatusError{StatusCode: resp.StatusCode, Body: string(b), Header: resp.Header}
+	}
+	return resp.Body, nil
+}
+
+// expand replaces aliases with their members. Must be called with o.mu held.
+func (o *Resolver) expand(names []string) ([]string, error) {
+	if o.aliases == nil {
+		o.aliases = map[string][]string{}
+		body, err := o.fetch("OWNERS_ALIASES")
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			o.aliases = parse(body)
+			body.Close()
+		}
+	}
+
+	var expanded []string
+	for _, n := range names {
+		if members, ok := o.aliases[n]; ok {
+			expanded = append(expanded, members...)
+		} else {
+			expanded = append(expanded, n)
+		}
+	}
+	return expanded, nil
+}
+
+// ownersOf returns the OWNERS file in dir, nil when there is none.
+func (o *Resolver) ownersOf(dir string) (*Owners, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if f, ok := o.files[dir]; ok {
+		return f, nil
+	}
+
+	body, err := o.fetch(path.Join(dir, "OWNERS"))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		o.files[dir] = nil
+		return nil, nil
+	}
+	defer body.Close()
+
+	lists := parse(body)
+	f := &Owners{Labels: lists["labels"]}
+	if f.Approvers, err = o.expand(lists["approvers"]); err != nil {
+		return nil, err
+	}
+	if f.Reviewers, err = o.expand(lists["reviewers"]); err != nil {
+		return nil, err
+	}
+	o.files[dir] = f
+	return f, nil
+}
+
+// Nearest returns the directory and content of the closest OWNERS file with
+// approvers above the file.
+func (o *Resolver) Nearest(file string) (string, *Owners, error) {
+	dir := path.Dir(file)
+	for {
+		if dir == "." {
+			dir = ""
+		}
+		f, err := o.ownersOf(dir)
+		if err != nil {
+			return "", nil, err
+		}
+		if f != nil && len(f.Approvers) > 0 {
+			return dir, f, nil
+		}
+		if dir == "" {
+			return "", nil, nil
+		}
+		dir = path.Dir(dir)
+	}
+}
@@ -0,0 +1,93 @@
+package owners
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name, file string
+		want       string
+	}{
+		{"lists", "approvers:\n- a\n- b\nreviewers:\n- c\n", "map[approvers:[a b] reviewers:[c]]"},
+		{"comments and quotes", "# top\napprovers: # people\n  - \"a\" # lead\n  - 'b'\n", "map[approvers:[a b]]"},
+		{"filters flattened", "filters:\n  \".*\":\n    approvers:\n    - a\n  \"\\\\.go$\":\n    approvers:\n    - b\n", "map[approvers:[a b]]"},
+		{"aliases", "aliases:\n  sig-node-approvers:\n  - a\n  - b\n", "map[sig-node-approvers:[a b]]"},
+		{"labels", "labels:\n- sig/node\n- area/kubelet\n", "map[labels:[sig/node area/kubelet]]"},
+		{"options ignored", "options:\n  no_parent_owners: true\napprovers:\n- a\n", "map[approvers:[a]]"},
+		{"empty", "", "map[]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fmt.Sprint(parse(strings.NewReader(tc.file))); got != tc.want {
+				t.Errorf("parse = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// redirect sends every request to the test server instead of GitHub.
+type redirect struct{ to *url.URL }
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = r.to.Scheme, r.to.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNearest(t *testing.T) {
+	files := map[string]string{
+		"OWNERS":                "approvers:\n- root\n",
+		"OWNERS_ALIASES":        "aliases:\n  kubelet-approvers:\n  - a\n  - b\n",
+		"pkg/kubelet/OWNERS":    "approvers:\n- kubelet-approvers\nreviewers:\n- c\nlabels:\n- area/kubelet\n",
+		"pkg/kubelet/cm/OWNERS": "reviewers:\n- d\n",
+	}
+	var mu sync.Mutex
+	fetched := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file := strings.TrimPrefix(r.URL.Path, "/o/r/main/")
+		mu.Lock()
+		fetched[file]++
+		mu.Unlock()
+		if content, ok := files[file]; ok {
+			fmt.Fprint(w, content)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	defer func(c *http.Client) { ghclient.HTTPClient = c }(ghclient.HTTPClient)
+	ghclient.HTTPClient = &http.Client{Transport: redirect{u}}
+
+	o := NewResolver("o/r", "main")
+	for _, tc := range []struct {
+		file, dir, approvers string
+	}{
+		{"pkg/kubelet/kubelet.go", "pkg/kubelet", "[a b]"},
+		// cm/OWNERS has no approvers, so kubelet approves
+		{"pkg/kubelet/cm/devicemanager/manager.go", "pkg/kubelet", "[a b]"},
+		{"pkg/proxy/proxy.go", "", "[root]"},
+		{"README.md", "", "[root]"},
+	} {
+		dir, f, err := o.Nearest(tc.file)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.file, err)
+		}
+		if dir != tc.dir || f == nil || fmt.Sprint(f.Approvers) != tc.approvers {
+			t.Errorf("Nearest(%s) = %q, %+v; want %q with approvers %s", tc.file, dir, f, tc.dir, tc.approvers)
+		}
+	}
+	for file, n := range fetched {
+		if n != 1 {
+			t.Errorf("fetched %s %d times, want once", file, n)
+		}
+	}
+}
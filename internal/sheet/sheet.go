@@ -0,0 +1,210 @@
+// Package sheet writes to the sig/node Google spreadsheet, authenticated
+// with the service account credentials in SHEETS_CREDENTIALS
+// (credentials.json by default).
+package sheet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/option"
+	sheets "google.golang.org/api/sheets/v4"
+)
+
+// https://docs.google.com/spreadsheets/d/1VW5_Eq8MzswfDi9xEvfYyP8edF_Ny7MBANIsJXT3VGw/edit
+const SpreadsheetID = "1VW5_Eq8MzswfDi9xEvfYyP8edF_Ny7MBANIsJXT3VGw"
+
+func credentialsFile() string {
+	if path := os.Getenv("SHEETS_CREDENTIALS"); path != "" {
+		return path
+	}
+	return "credentials.json"
+}
+
+// Service returns a Sheets client.
+func Service() (*sheets.Service, error) {
+	// Service account based oauth2 two legged integration
+	ctx := context.Background()
+	srv, err := sheets.NewService(ctx, option.WithAuthCredentialsFile(option.ServiceAccount, credentialsFile()), option.WithScopes(sheets.SpreadsheetsScope))
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve Sheets client: %v", err)
+	}
+	return srv, nil
+}
+
+// Hyperlinks reports whether counts are written as links to the GitHub
+// search they count. SHEETS_HYPERLINKS=true links the counts of every tab
+// and false none of them; without it each command keeps its default.
+func Hyperlinks(def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv("SHEETS_HYPERLINKS")); err == nil {
+		return v
+	}
+	return def
+}
+
+// Link returns a cell showing value that links to url. Numbers stay numbers,
+// so charts over linked cells still work. It is a formula, so the row has to
+// be written with USER_ENTERED.
+func Link(url string, value interface{}) string {
+	label := fmt.Sprint(value)
+	if s, ok := value.(string); ok {
+		label = `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return fmt.Sprintf("=HYPERLINK(\"%s\", %s)", strings.ReplaceAll(url, `"`, `""`), label)
+}
+
+// Text returns a cell that USER_ENTERED keeps as the text s, rather than
+// parsing it as a date or a number.
+func Text(s string) string {
+	return "'" + s
+}
+
+// has reports whether the spreadsheet has the sheet tab.
+func has(srv *sheets.Service, sheet string) (bool, error) {
+	ss, err := srv.Spreadsheets.Get(SpreadsheetID).Fields("sheets.properties.title").Do()
+	if err != nil {
+		return false, fmt.Errorf("unable to get spreadsheet: %v", err)
+	}
+	for _, s := range ss.Sheets {
+		if s.Properties.Title == sheet {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Ensure adds the sheet tab when the spreadsheet does not have it yet and
+// reports whether it was there already.
+func Ensure(srv *sheets.Service, sheet string) (bool, error) {
+	exists, err := has(srv, sheet)
+	if err != nil || exists {
+		return exists, err
+	}
+
+	_, err = srv.Spreadsheets.BatchUpdate(SpreadsheetID, &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: sheet}}}},
+	}).Do()
+	if err != nil {
+		return false, fmt.Errorf("unable to add sheet %s: %v", sheet, err)
+	}
+	return false, nil
+}
+
+// Read returns the rows of a sheet tab from firstRow on, or nothing when
+// the spreadsheet does not have the tab.
+func Read(sheet string, firstRow int) ([][]interface{}, error) {
+	srv, err := Service()
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := has(srv, sheet)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	resp, err := srv.Spreadsheets.Values.Get(SpreadsheetID, sheet).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read sheet: %v", err)
+	}
+	if firstRow > len(resp.Values) {
+		return nil, nil
+	}
+	return resp.Values[firstRow-1:], nil
+}
+
+// Tail returns the last n rows of a sheet tab from firstRow on, or nothing
+// when the spreadsheet does not have the tab. Only the first column of the
+// older rows is read, to find where the tab ends.
+func Tail(sheet string, firstRow, n int) ([][]interface{}, error) {
+	srv, err := Service()
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := has(srv, sheet)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	resp, err := srv.Spreadsheets.Values.Get(SpreadsheetID, fmt.Sprintf("%s!A%d:A", sheet, firstRow)).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read sheet: %v", err)
+	}
+	last := firstRow + len(resp.Values) - 1
+	if last < firstRow {
+		return nil, nil
+	}
+	from := max(firstRow, last-n+1)
+
+	resp, err = srv.Spreadsheets.Values.Get(SpreadsheetID, fmt.Sprintf("%s!%d:%d", sheet, from, last)).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read sheet: %v", err)
+	}
+	return resp.Values, nil
+}
+
+// Replace overwrites the sheet tab with rows, for reports that are a table
+// of the current state rather than a row per snapshot.
+func Replace(sheet string, rows [][]interface{}) error {
+	srv, err := Service()
+	if err != nil {
+		return err
+	}
+
+	if _, err := Ensure(srv, sheet); err != nil {
+		return err
+	}
+
+	_, err = srv.Spreadsheets.Values.Clear(SpreadsheetID, sheet, &sheets.ClearValuesRequest{}).Do()
+	if err != nil {
+		return fmt.Errorf("unable to clear sheet: %v", err)
+	}
+
+	_, err = srv.Spreadsheets.Values.Update(SpreadsheetID, sheet+"!A1", &sheets.ValueRange{Values: rows}).ValueInputOption("USER_ENTERED").Do()
+	if err != nil {
+		return fmt.Errorf("unable to write data to sheet: %v", err)
+	}
+	return nil
+}
+
+// Row is a row to append to a sheet tab, below the rows from FirstRow on.
+type Row struct {
+	Sheet    string
+	FirstRow int
+	Values   []interface{}
+}
+
+// Append writes every row to the first empty row of its tab with a single
+// batch update, so either every tab gets its row or none does. valueInput
+// is RAW to store the values as they are or USER_ENTERED to have formulas
+// such as HYPERLINK parsed.
+func Append(valueInput string, rows ...Row) error {
+	srv, err := Service()
+	if err != nil {
+		return err
+	}
+
+	update := &sheets.BatchUpdateValuesRequest{ValueInputOption: valueInput}
+	for _, r := range rows {
+		readRange := fmt.Sprintf("%s!A%d:A", r.Sheet, r.FirstRow)
+		resp, err := srv.Spreadsheets.Values.Get(SpreadsheetID, readRange).Do()
+		if err != nil {
+			return fmt.Errorf("unable to retrieve data from sheet: %v", err)
+		}
+
+		update.Data = append(update.Data, &sheets.ValueRange{
+			Range:  fmt.Sprintf("%s!A%d", r.Sheet, len(resp.Values)+r.FirstRow),
+			Values: [][]interface{}{r.Values},
+		})
+	}
+
+	_, err = srv.Spreadsheets.Values.BatchUpdate(SpreadsheetID, update).Do()
+	if err != nil {
+		return fmt.Errorf("unable to write data to sheet: %v", err)
+	}
+	return nil
+}
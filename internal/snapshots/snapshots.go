@@ -0,0 +1,283 @@
+// Package snapshots is the local store of the counts of every column set:
+// a JSON lines file per column set in SNAPSHOT_DIR, one snapshot per run,
+// oldest first. Without SNAPSHOT_DIR nothing is stored and there is no
+// history.
+package snapshots
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+)
+
+// Column is the count of one search.
+type Column struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	// Count is nil when the column could not be counted.
+	Count *int `json:"count"`
+	// Items are the owner/repo#number of the counted items, listed by
+	// the same search as Count with SNAPSHOT_ITEMS set, nil when they
+	// were not listed.
+	Items []string `json:"items"`
+}
+
+// Snapshot is the counts of a column set at one run.
+type Snapshot struct {
+	Time    time.Time `json:"time"`
+	Columns []Column  `json:"columns"`
+}
+
+// Queries returns the queries of the columns, in their order.
+func Queries(columns []Column) []string {
+	var queries []string
+	for _, c := range columns {
+		queries = append(queries, c.Query)
+	}
+	return queries
+}
+
+// FromRow reads the counts of the columns from a sheet row, where the first
+// column starts at offset. Cells that are not numbers are missing counts.
+func FromRow(row []interface{}, offset int, columns []Column) *Snapshot {
+	s := &Snapshot{}
+	for i, c := range columns {
+		sc := Column{Name: c.Name, Query: c.Query}
+		if offset+i < len(row) {
+			if n, err := strconv.Atoi(strings.TrimSpace(fmt.Sprint(row[offset+i]))); err == nil {
+				sc.Count = &n
+			}
+		}
+		s.Columns = append(s.Columns, sc)
+	}
+	return s
+}
+
+// Count returns the count of the named column, if the snapshot has one.
+func (s *Snapshot) Count(name string) (int, bool) {
+	if s == nil {
+		return 0, false
+	}
+	for _, c := range s.Columns {
+		if c.Name == name && c.Count != nil {
+			return *c.Count, true
+		}
+	}
+	return 0, false
+}
+
+// Changes returns the items of the named column that entered it since the
+// previous snapshot and those that left it, if both snapshots listed them.
+func (s *Snapshot) Changes(previous *Snapshot, name string) (entered, left []string, ok bool) {
+	current, ok := s.items(name)
+	if !ok {
+		return nil, nil, false
+	}
+	before, ok := previous.items(name)
+	if !ok {
+		return nil, nil, false
+	}
+	return missing(current, before), missing(before, current), true
+}
+
+func (s *Snapshot) items(name string) ([]string, bool) {
+	if s == nil {
+		return nil, false
+	}
+	for _, c := range s.Columns {
+		if c.Name == name && c.Items != nil {
+			return c.Items, true
+		}
+	}
+	return nil, false
+}
+
+// missing returns the items of a that b does not have, in the order of a.
+func missing(a, b []string) []string {
+	in := map[string]bool{}
+	for _, i := range b {
+		in[i] = true
+	}
+	var result []string
+	for _, i := range a {
+		if !in[i] {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// Listing reports whether SNAPSHOT_ITEMS asks to list the items behind the
+// counts, so later runs can tell which items entered or left a column.
+func Listing() bool {
+	on, _ := strconv.ParseBool(os.Getenv("SNAPSHOT_ITEMS"))
+	return on
+}
+
+func path(set string) string {
+	dir := os.Getenv("SNAPSHOT_DIR")
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, set+".jsonl")
+}
+
+// Load returns the stored snapshots of the column set, oldest first.
+func Load(set string) ([]Snapshot, error) {
+	path := path(set)
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snapshots []Snapshot
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var s Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			logging.Logger.Warn("skipping corrupt snapshot", "set", set, "err", err)
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, scanner.Err()
+}
+
+// Before returns the last stored snapshot of the column set taken at or
+// before t, or nil.
+func Before(set string, t time.Time) (*Snapshot, error) {
+	snapshots, err := Load(set)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !snapshots[i].Time.After(t) {
+			return &snapshots[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Latest returns the last stored snapshot of the column set, or nil.
+func Latest(set string) (*Snapshot, error) {
+	snapshots, err := Load(set)
+	if err != nil || len(snapshots) == 0 {
+		return nil, err
+	}
+	return &snapshots[len(snapshots)-1], nil
+}
+
+// Append adds the snapshot to the store of the column set.
+func Append(set string, s Snapshot) error {
+	path := path(set)
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Prepend adds the snapshots taken before the first stored one to the
+// store of the column set, for backfills from older records, and returns
+// how many were added. The store is rewritten to a temporary file first so
+// a failed backfill leaves it as it was.
+func Prepend(set string, older []Snapshot) (int, error) {
+	path := path(set)
+	if path == "" {
+		return 0, fmt.Errorf("SNAPSHOT_DIR is not set")
+	}
+	stored, err := Load(set)
+	if err != nil {
+		return 0, err
+	}
+
+	var added []Snapshot
+	for _, s := range older {
+		if len(stored) == 0 || s.Time.Before(stored[0].Time) {
+			added = append(added, s)
+		}
+	}
+	if len(added) == 0 {
+		return 0, nil
+	}
+	sort.SliceStable(added, func(i, j int) bool { return added[i].Time.Before(added[j].Time) })
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), set+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	for _, s := range append(added, stored...) {
+		b, err := json.Marshal(s)
+		if err != nil {
+			f.Close()
+			return 0, err
+		}
+		w.Write(append(b, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	return len(added), os.Rename(f.Name(), path)
+}
+
+// Sets returns the column sets in the store.
+func Sets() ([]string, error) {
+	dir := os.Getenv("SNAPSHOT_DIR")
+	if dir == "" {
+		return nil, fmt.Errorf("SNAPSHOT_DIR is not set")
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []string
+	for _, f := range files {
+		sets = append(sets, strings.TrimSuffix(filepath.Base(f), ".jsonl"))
+	}
+	sort.Strings(sets)
+	return sets, nil
+}
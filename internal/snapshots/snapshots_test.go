@@ -0,0 +1,134 @@
+package snapshots
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func count(n int) *int { return &n }
+
+func TestFromRow(t *testing.T) {
+	columns := []Column{{Name: "a", Query: "qa"}, {Name: "b", Query: "qb"}, {Name: "c", Query: "qc"}}
+
+	for _, tc := range []struct {
+		name   string
+		row    []interface{}
+		offset int
+		want   string
+	}{
+		{"counts", []interface{}{"date", "3", "4", "5"}, 1, "[3 4 5]"},
+		{"numbers and spaces", []interface{}{"date", 3, " 4 ", 5.0}, 1, "[3 4 5]"},
+		{"fractions", []interface{}{"date", 3.5, "4.5", "5"}, 1, "[- - 5]"},
+		{"not numbers", []interface{}{"date", "n/a", "", "5"}, 1, "[- - 5]"},
+		{"short row", []interface{}{"date", "3"}, 1, "[3 - -]"},
+		{"empty row", nil, 1, "[- - -]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := FromRow(tc.row, tc.offset, columns)
+			var got []string
+			for i, c := range s.Columns {
+				if c.Name != columns[i].Name || c.Query != columns[i].Query {
+					t.Errorf("column %d is %s %q, want %s %q", i, c.Name, c.Query, columns[i].Name, columns[i].Query)
+				}
+				if n, ok := s.Count(c.Name); ok {
+					got = append(got, fmt.Sprint(n))
+				} else {
+					got = append(got, "-")
+				}
+			}
+			if fmt.Sprint(got) != tc.want {
+				t.Errorf("counts %v, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChanges(t *testing.T) {
+	listed := func(items ...string) *Snapshot {
+		if items == nil {
+			items = []string{}
+		}
+		return &Snapshot{Columns: []Column{{Name: "c", Count: count(len(items)), Items: items}}}
+	}
+	counted := &Snapshot{Columns: []Column{{Name: "c", Count: count(2)}}}
+
+	for _, tc := range []struct {
+		name              string
+		current, previous *Snapshot
+		entered, left     string
+		ok                bool
+	}{
+		{"entered and left", listed("o/r#1", "o/r#2", "o/r#3"), listed("o/r#2", "o/r#4"), "[o/r#1 o/r#3]", "[o/r#4]", true},
+		{"unchanged", listed("o/r#1"), listed("o/r#1"), "[]", "[]", true},
+		{"all new", listed("o/r#1"), listed(), "[o/r#1]", "[]", true},
+		{"emptied", listed(), listed("o/r#1"), "[]", "[o/r#1]", true},
+		{"previous not listed", listed("o/r#1"), counted, "[]", "[]", false},
+		{"current not listed", counted, listed("o/r#1"), "[]", "[]", false},
+		{"no previous", listed("o/r#1"), nil, "[]", "[]", false},
+		{"other column", listed("o/r#1"), &Snapshot{Columns: []Column{{Name: "d", Items: []string{"o/r#1"}}}}, "[]", "[]", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			entered, left, ok := tc.current.Changes(tc.previous, "c")
+			if fmt.Sprint(entered) != tc.entered || fmt.Sprint(left) != tc.left || ok != tc.ok {
+				t.Errorf("Changes = %v, %v, %v; want %s, %s, %v", entered, left, ok, tc.entered, tc.left, tc.ok)
+			}
+		})
+	}
+}
+
+func TestPrepend(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+	at := func(days ...int) []Snapshot {
+		var s []Snapshot
+		for _, d := range days {
+			s = append(s, Snapshot{Time: day(d), Columns: []Column{{Name: "c", Count: count(d)}}})
+		}
+		return s
+	}
+	days := func(s []Snapshot) string {
+		var d []int
+		for _, s := range s {
+			d = append(d, s.Time.Day())
+		}
+		return fmt.Sprint(d)
+	}
+
+	for _, tc := range []struct {
+		name          string
+		stored, older []Snapshot
+		added         int
+		want          string
+	}{
+		{"empty store", nil, at(3, 1, 2), 3, "[1 2 3]"},
+		{"before the first", at(5, 6), at(2, 1), 2, "[1 2 5 6]"},
+		{"overlapping", at(5, 6), at(4, 5, 7), 1, "[4 5 6]"},
+		{"nothing older", at(5, 6), at(5, 8), 0, "[5 6]"},
+		{"nothing", at(5), nil, 0, "[5]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("SNAPSHOT_DIR", t.TempDir())
+			for _, s := range tc.stored {
+				if err := Append("s", s); err != nil {
+					t.Fatal(err)
+				}
+			}
+			added, err := Prepend("s", tc.older)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Load("s")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if added != tc.added || days(got) != tc.want {
+				t.Errorf("Prepend added %d, store has days %s; want %d, %s", added, days(got), tc.added, tc.want)
+			}
+		})
+	}
+
+	t.Setenv("SNAPSHOT_DIR", "")
+	if _, err := Prepend("s", at(1)); err == nil {
+		t.Error("Prepend without SNAPSHOT_DIR succeeded")
+	}
+}
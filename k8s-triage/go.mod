@@ -1,16 +0,0 @@
-module main
-
-require (
-	github.com/google/go-github/v40 v40.0.0
-	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
-)
-
-require (
-	github.com/golang/protobuf v1.3.5 // indirect
-	github.com/google/go-querystring v1.1.0 // indirect
-	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5 // indirect
-	golang.org/x/net v0.0.0-20210226172049-e18ecbb05110 // indirect
-	google.golang.org/appengine v1.6.7 // indirect
-)
-
-go 1.19
@@ -0,0 +1,91 @@
+// Command github-queries runs the sig/node GitHub queries: the counts
+// behind the sheets and dashboards, the weekly and test failures reports,
+// the triage of new items and the triage server.
+//
+//	github-queries <command> [flags]
+//
+// Every command shares the configuration of internal/ghclient (ACCESS_TOKEN,
+// HTTP_CACHE_DIR), the snapshot store (SNAPSHOT_DIR) and the sinks
+// (SHEETS_CREDENTIALS, NOTIFY_WEBHOOKS, METRICS_PUSHGATEWAY).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/metrics"
+	"github.com/SergeyKanzhelev/github-queries/prs"
+	"github.com/SergeyKanzhelev/github-queries/testfailures"
+	"github.com/SergeyKanzhelev/github-queries/triage"
+	"github.com/SergeyKanzhelev/github-queries/weekly"
+)
+
+type command struct {
+	run func(args []string) error
+	// job is the metrics job the command pushes to, if any.
+	job   string
+	usage string
+}
+
+var commands = map[string]command{
+	"count":        {prs.Count, "sig-node-prs", "count the PRs and Bugs columns into the sheet"},
+	"stale":        {prs.Stale, "sig-node-prs-stale", "report and nudge stale items"},
+	"reviewers":    {prs.Reviewers, "sig-node-prs-reviewers", "report who the open PRs wait on"},
+	"burndown":     {prs.Burndown, "sig-node-prs-burndown", "record the burndown of a milestone"},
+	"cherrypicks":  {prs.CherryPicks, "sig-node-prs-cherrypicks", "write the open cherry picks per release branch"},
+	"dashboard":    {prs.Dashboard, "sig-node-prs-dashboard", "write the static dashboard site"},
+	"weekly":       {weekly.Run, "sig-node-weekly", "count the PRs of the week since the last meeting"},
+	"testfailures": {testfailures.Run, "sig-node-testfailures", "report the failing-test issues"},
+	"triage":       {triage.Triage, "", "add new items to the triage columns"},
+	"serve":        {triage.Serve, "", "run the triage and dashboards server"},
+	"backfill":     {backfill, "", "store the sheet rows older than the snapshot store"},
+}
+
+// backfill fills the snapshot store with the rows the sheets had before
+// it was kept.
+func backfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only log how many snapshots would be stored")
+	fs.Parse(args)
+
+	if err := prs.Backfill(*dryRun); err != nil {
+		return err
+	}
+	return weekly.Backfill(*dryRun)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-13s %s\n", name, commands[name].usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(os.Args[2:])
+	if cmd.job != "" {
+		metrics.Flush(cmd.job)
+	}
+	if err != nil {
+		logging.Logger.Error("run failed", "command", os.Args[1], "err", err)
+		os.Exit(1)
+	}
+}
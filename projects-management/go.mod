@@ -1,7 +0,0 @@
-module main
-
-require google.golang.org/api v0.29.0
-
-require github.com/google/go-github/v40 v40.0.0 // indirect
-
-go 1.12
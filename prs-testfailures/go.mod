@@ -1,8 +0,0 @@
-module main
-
-require google.golang.org/api v0.29.0
-
-require (
-)
-
-go 1.12
@@ -0,0 +1,60 @@
+package prs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/sheet"
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+)
+
+// Backfill stores the PRs and Bugs rows written to the sheet before the
+// snapshot store was kept as snapshots, so the history and the deltas reach
+// back to the first row. Rows are read in the current column order and
+// rows newer than the first stored snapshot are left alone. With dryRun
+// the snapshots are only counted.
+func Backfill(dryRun bool) error {
+	tabs := []tab{
+		{"Sheet1", "PRs", PRColumns()},
+		// the date windows of the bug columns end at the time of each row
+		{"Bugs", "Bugs", nil},
+	}
+
+	for _, t := range tabs {
+		rows, err := sheet.Read(t.Sheet, 2)
+		if err != nil {
+			return err
+		}
+
+		var older []snapshots.Snapshot
+		for _, r := range rows {
+			if len(r) == 0 {
+				continue
+			}
+			written, err := time.Parse("01/02/2006 15:04", fmt.Sprint(r[0]))
+			if err != nil {
+				continue
+			}
+			columns := t.Columns
+			if columns == nil {
+				columns = BugColumns(written)
+			}
+
+			s := snapshots.FromRow(r, 1, columns)
+			s.Time = written
+			older = append(older, *s)
+		}
+
+		if dryRun {
+			logging.Logger.Info("would backfill snapshots", "sheet", t.Sheet, "rows", len(older))
+			continue
+		}
+		n, err := snapshots.Prepend(t.set(), older)
+		if err != nil {
+			return fmt.Errorf("failed to backfill %s: %v", t.set(), err)
+		}
+		logging.Logger.Info("backfilled snapshots", "sheet", t.Sheet, "set", t.set(), "added", n)
+	}
+	return nil
+}
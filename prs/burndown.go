@@ -0,0 +1,297 @@
+package prs
+
+import (
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/sheet"
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+)
+
+// Milestone burndown: every run records the open and closed sig/node issues
+// and PRs of a milestone in a row per day of a dedicated sheet tab, next to
+// the ideal burndown from the milestone start to code freeze, and draws the
+// history as an SVG chart. The sig-node-prs-burndown CronJob records the
+// milestone in progress every day.
+
+type milestone struct {
+	Name       string `json:"name"`
+	Start      string `json:"start"`
+	CodeFreeze string `json:"code_freeze"`
+}
+
+type milestonesConfig struct {
+	Milestones []milestone `json:"milestones"`
+}
+
+//go:embed milestones.json
+var defaultMilestones []byte
+
+const burndownDate = "2006-01-02"
+
+// burndownDay is one row of the burndown tab.
+type burndownDay struct {
+	Date         time.Time
+	OpenIssues   int
+	ClosedIssues int
+	OpenPRs      int
+	ClosedPRs    int
+}
+
+func (d burndownDay) open() int {
+	return d.OpenIssues + d.OpenPRs
+}
+
+// dates returns the start and code freeze of the milestone.
+func (m milestone) dates() (start, freeze time.Time, err error) {
+	if start, err = time.Parse(burndownDate, m.Start); err != nil {
+		return start, freeze, fmt.Errorf("bad start of %s: %v", m.Name, err)
+	}
+	if freeze, err = time.Parse(burndownDate, m.CodeFreeze); err != nil {
+		return start, freeze, fmt.Errorf("bad code_freeze of %s: %v", m.Name, err)
+	}
+	return start, freeze, nil
+}
+
+// loadMilestone returns the named milestone or, without a name, the one in
+// progress on day; nil when no milestone is in progress.
+func loadMilestone(path, name string, day time.Time) (*milestone, error) {
+	b := defaultMilestones
+	if path != "" {
+		var err error
+		if b, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var cfg milestonesConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse milestones config: %v", err)
+	}
+	for i, m := range cfg.Milestones {
+		if name != "" {
+			if m.Name == name {
+				return &cfg.Milestones[i], nil
+			}
+			continue
+		}
+		start, freeze, err := m.dates()
+		if err != nil {
+			return nil, err
+		}
+		if !day.Before(start) && !day.After(freeze) {
+			return &cfg.Milestones[i], nil
+		}
+	}
+	if name == "" {
+		if n := len(cfg.Milestones); n > 0 {
+			if _, freeze, _ := cfg.Milestones[n-1].dates(); day.After(freeze) {
+				logging.Logger.Warn("milestones config ends before today, add the next release", "last", cfg.Milestones[n-1].Name)
+			}
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("milestone %s is not in the milestones config", name)
+}
+
+// Burndown records the burndown of a milestone and draws its chart.
+func Burndown(args []string) error {
+	fs := flag.NewFlagSet("burndown", flag.ExitOnError)
+	name := fs.String("milestone", "", "milestone to track, e.g. v1.35; defaults to the milestone in progress")
+	config := fs.String("config", "", "milestones config with code freeze dates; defaults to the built in milestones.json")
+	repo := fs.String("repo", "kubernetes/kubernetes", "repository of the milestone")
+	svg := fs.String("svg", "", "SVG chart to write; defaults to burndown-<milestone>.svg")
+	fs.Parse(args)
+
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	m, err := loadMilestone(*config, *name, day)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		logging.Logger.Info("no milestone in progress, nothing to record")
+		return nil
+	}
+	start, freeze, err := m.dates()
+	if err != nil {
+		return err
+	}
+	if *svg == "" {
+		*svg = "burndown-" + m.Name + ".svg"
+	}
+
+	baseQuery := fmt.Sprintf("repo:%s label:sig/node milestone:%s ", *repo, m.Name)
+	columns := []snapshots.Column{
+		{Name: "open issues", Query: baseQuery + "is:issue is:open"},
+		{Name: "closed issues", Query: baseQuery + "is:issue is:closed"},
+		{Name: "open PRs", Query: baseQuery + "is:pr is:open"},
+		{Name: "closed PRs", Query: baseQuery + "is:pr is:closed"},
+	}
+	counts, errs := ghclient.Counts(snapshots.Queries(columns))
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("failed to count %s: %v", columns[i].Name, err)
+		}
+	}
+
+	today := burndownDay{
+		Date:         day,
+		OpenIssues:   counts[0],
+		ClosedIssues: counts[1],
+		OpenPRs:      counts[2],
+		ClosedPRs:    counts[3],
+	}
+
+	tabName := "Burndown " + m.Name
+	history, err := readBurndown(tabName)
+	if err != nil {
+		return err
+	}
+	if n := len(history); n > 0 && history[n-1].Date.Equal(today.Date) {
+		history[n-1] = today
+	} else {
+		history = append(history, today)
+	}
+
+	if err := sheet.Replace(tabName, burndownRows(history, start, freeze)); err != nil {
+		return err
+	}
+
+	f, err := os.Create(*svg)
+	if err != nil {
+		return err
+	}
+	writeBurndownSVG(f, m.Name, history, start, freeze)
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	logging.Logger.Info("recorded burndown", "milestone", m.Name, "open", today.open(), "days_to_code_freeze", int(freeze.Sub(today.Date).Hours()/24), "svg", *svg)
+	return nil
+}
+
+// idealOpen is the open count on a straight line from the milestone start
+// down to zero at code freeze. The line starts at the open count of the
+// first recorded day, the closest to the start there is.
+func idealOpen(history []burndownDay, start, freeze, date time.Time) float64 {
+	total := freeze.Sub(start)
+	if total <= 0 || !date.Before(freeze) {
+		return 0
+	}
+	if date.Before(start) {
+		date = start
+	}
+	return float64(history[0].open()) * float64(freeze.Sub(date)) / float64(total)
+}
+
+func burndownRows(history []burndownDay, start, freeze time.Time) [][]interface{} {
+	rows := [][]interface{}{
+		{"date", "open issues", "closed issues", "open PRs", "closed PRs", "open total", "ideal open", "days to code freeze"},
+	}
+	for _, d := range history {
+		rows = append(rows, []interface{}{
+			d.Date.Format(burndownDate),
+			d.OpenIssues, d.ClosedIssues, d.OpenPRs, d.ClosedPRs,
+			d.open(),
+			strconv.FormatFloat(idealOpen(history, start, freeze, d.Date), 'f', 1, 64),
+			int(freeze.Sub(d.Date).Hours() / 24),
+		})
+	}
+	return rows
+}
+
+// readBurndown returns the days recorded in the tab so far; none when the
+// tab does not exist yet.
+func readBurndown(name string) ([]burndownDay, error) {
+	rows, err := sheet.Read(name, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []burndownDay
+	for i, row := range rows {
+		if i == 0 || len(row) < 5 {
+			continue // header
+		}
+		date, err := time.Parse(burndownDate, fmt.Sprint(row[0]))
+		if err != nil {
+			continue
+		}
+		d := burndownDay{Date: date}
+		for j, v := range []*int{&d.OpenIssues, &d.ClosedIssues, &d.OpenPRs, &d.ClosedPRs} {
+			*v, _ = strconv.Atoi(fmt.Sprint(row[j+1]))
+		}
+		history = append(history, d)
+	}
+	return history, nil
+}
+
+func writeBurndownSVG(w io.Writer, name string, history []burndownDay, milestoneStart, freeze time.Time) {
+	const width, height, margin = 800, 400, 50
+
+	start := history[0].Date
+	if milestoneStart.Before(start) {
+		start = milestoneStart
+	}
+	end := freeze
+	if last := history[len(history)-1].Date; last.After(end) {
+		end = last
+	}
+	span := end.Sub(start)
+	if span <= 0 {
+		span = 24 * time.Hour
+	}
+
+	maxOpen := 1
+	for _, d := range history {
+		if d.open() > maxOpen {
+			maxOpen = d.open()
+		}
+	}
+
+	x := func(t time.Time) float64 {
+		return margin + float64(width-2*margin)*float64(t.Sub(start))/float64(span)
+	}
+	y := func(v float64) float64 {
+		return height - margin - float64(height-2*margin)*v/float64(maxOpen)
+	}
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", width, height)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(w, `<text x="%d" y="20" font-size="16">sig/node %s burndown</text>`+"\n", margin, html.EscapeString(name))
+
+	// axes
+	fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`+"\n", margin, height-margin, width-margin, height-margin)
+	fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`+"\n", margin, margin, margin, height-margin)
+	fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end">%d</text>`+"\n", margin-5, margin+4, maxOpen)
+	fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="end">0</text>`+"\n", margin-5, height-margin+4)
+	fmt.Fprintf(w, `<text x="%d" y="%d">%s</text>`+"\n", margin, height-margin+20, start.Format(burndownDate))
+
+	// code freeze
+	fmt.Fprintf(w, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="red" stroke-dasharray="4"/>`+"\n", x(freeze), margin, x(freeze), height-margin)
+	fmt.Fprintf(w, `<text x="%.1f" y="%d" text-anchor="middle" fill="red">code freeze %s</text>`+"\n", x(freeze), height-margin+20, freeze.Format(burndownDate))
+
+	// ideal
+	fmt.Fprintf(w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="gray" stroke-dasharray="6 3"/>`+"\n",
+		x(milestoneStart), y(float64(history[0].open())), x(freeze), y(0))
+
+	// actual
+	fmt.Fprintf(w, `<polyline fill="none" stroke="steelblue" stroke-width="2" points="`)
+	for _, d := range history {
+		fmt.Fprintf(w, "%.1f,%.1f ", x(d.Date), y(float64(d.open())))
+	}
+	fmt.Fprintf(w, `"/>`+"\n")
+
+	fmt.Fprintf(w, `<text x="%d" y="%d" fill="steelblue">open issues and PRs</text>`+"\n", width-margin-200, margin)
+	fmt.Fprintf(w, `<text x="%d" y="%d" fill="gray">ideal</text>`+"\n", width-margin-200, margin+16)
+	fmt.Fprintf(w, "</svg>\n")
+}
@@ -0,0 +1,76 @@
+package prs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse(burndownDate, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestIdealOpen(t *testing.T) {
+	// 54 days
+	start, freeze := day("2026-09-14"), day("2026-11-07")
+	history := []burndownDay{{Date: day("2026-09-20"), OpenIssues: 40, OpenPRs: 66}}
+
+	for _, tc := range []struct {
+		name  string
+		start time.Time
+		date  string
+		want  float64
+	}{
+		{"at start", start, "2026-09-14", 106},
+		{"before start", start, "2026-09-01", 106},
+		{"halfway", start, "2026-10-11", 53},
+		{"at code freeze", start, "2026-11-07", 0},
+		{"after code freeze", start, "2026-11-20", 0},
+		{"freeze before start", freeze.AddDate(0, 0, 1), "2026-10-10", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := idealOpen(history, tc.start, freeze, day(tc.date)); got != tc.want {
+				t.Errorf("idealOpen on %s = %v, want %v", tc.date, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadMilestone(t *testing.T) {
+	for _, tc := range []struct {
+		name, milestone, day string
+		want                 string
+		err                  bool
+	}{
+		{"in progress", "", "2026-10-19", "v1.38", false},
+		{"first day", "", "2026-09-14", "v1.38", false},
+		{"code freeze", "", "2026-11-06", "v1.38", false},
+		{"between milestones", "", "2026-08-01", "", false},
+		{"named", "v1.36", "2026-10-19", "v1.36", false},
+		{"unknown", "v1.99", "2026-10-19", "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := loadMilestone("", tc.milestone, day(tc.day))
+			var got string
+			if m != nil {
+				got = m.Name
+			}
+			if got != tc.want || (err != nil) != tc.err {
+				t.Errorf("loadMilestone = %q, %v; want %q, error %v", got, err, tc.want, tc.err)
+			}
+		})
+	}
+}
+
+func TestBurndownSVGEscapesName(t *testing.T) {
+	var b strings.Builder
+	history := []burndownDay{{Date: day("2026-09-14"), OpenIssues: 1}}
+	writeBurndownSVG(&b, `v1.38 <"&">`, history, day("2026-09-14"), day("2026-11-06"))
+	if strings.Contains(b.String(), `<"&">`) || !strings.Contains(b.String(), "v1.38 &lt;&#34;&amp;&#34;&gt;") {
+		t.Errorf("name is not escaped in\n%s", b.String())
+	}
+}
@@ -0,0 +1,111 @@
+package prs
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+)
+
+// checkpoint records the columns counted so far in the current run. When a
+// run fails part way, the CronJob restarts the container (restartPolicy:
+// OnFailure) and the next attempt only queries the missing columns, keeping
+// the snapshot time of the first attempt.
+//
+// The checkpoint lives in CHECKPOINT_FILE, which should be on a volume that
+// survives container restarts but not the pod, such as an emptyDir. Without
+// it the checkpoint only lives in memory.
+type checkpoint struct {
+	Started  time.Time                 `json:"started"`
+	Attempts int                       `json:"attempts"`
+	Counts   map[string]map[string]int `json:"counts"`
+	// Items are the items listed with the counts, with SNAPSHOT_ITEMS set.
+	Items map[string]map[string][]string `json:"items,omitempty"`
+
+	path string
+}
+
+// checkpointMaxAge protects manual runs with a persistent CHECKPOINT_FILE from
+// resuming an unrelated, older run.
+const checkpointMaxAge = time.Hour
+
+func loadCheckpoint(path string) *checkpoint {
+	cp := &checkpoint{Started: time.Now(), Counts: map[string]map[string]int{}, path: path}
+	if path == "" {
+		return cp
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cp
+	}
+
+	var saved checkpoint
+	if err := json.Unmarshal(b, &saved); err != nil {
+		logging.Logger.Warn("ignoring unreadable checkpoint", "path", path, "err", err)
+		return cp
+	}
+	if time.Since(saved.Started) > checkpointMaxAge {
+		logging.Logger.Info("ignoring stale checkpoint", "path", path, "started", saved.Started)
+		return cp
+	}
+
+	saved.path = path
+	if saved.Counts == nil {
+		saved.Counts = map[string]map[string]int{}
+	}
+	logging.Logger.Info("resuming from checkpoint", "path", path, "started", saved.Started, "attempts", saved.Attempts)
+	return &saved
+}
+
+func (cp *checkpoint) save() error {
+	if cp.path == "" {
+		return nil
+	}
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := cp.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cp.path)
+}
+
+func (cp *checkpoint) remove() {
+	if cp.path == "" {
+		return
+	}
+	if err := os.Remove(cp.path); err != nil && !os.IsNotExist(err) {
+		logging.Logger.Warn("failed to remove checkpoint", "path", cp.path, "err", err)
+	}
+}
+
+func (cp *checkpoint) record(sheet, columnName string, count int, items []string) {
+	if cp.Counts[sheet] == nil {
+		cp.Counts[sheet] = map[string]int{}
+	}
+	cp.Counts[sheet][columnName] = count
+	if items == nil {
+		return
+	}
+	if cp.Items == nil {
+		cp.Items = map[string]map[string][]string{}
+	}
+	if cp.Items[sheet] == nil {
+		cp.Items[sheet] = map[string][]string{}
+	}
+	cp.Items[sheet][columnName] = items
+}
+
+// runAttempts is how many attempts a run gets before failed columns are
+// written as empty cells. Override with RUN_ATTEMPTS.
+func runAttempts() int {
+	if n, err := strconv.Atoi(os.Getenv("RUN_ATTEMPTS")); err == nil && n > 0 {
+		return n
+	}
+	return 3
+}
@@ -0,0 +1,163 @@
+package prs
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/metrics"
+	"github.com/SergeyKanzhelev/github-queries/internal/sheet"
+)
+
+// Cherry picks are tracked per release branch: the open sig/node PRs of
+// every supported release-1.x branch, with the age of the oldest one and a
+// link to the search, replace the contents of the "Cherry picks" tab. The
+// sig-node-prs-cherrypicks CronJob refreshes the tab every hour, apart from
+// the counts.
+
+const cherryPicksSheet = "Cherry picks"
+
+// supportedReleases is how many of the newest release branches are
+// tracked; PRs to older branches are counted as "other".
+const supportedReleases = 4
+
+var releaseBranch = regexp.MustCompile(`^release-1\.(\d+)$`)
+
+// releaseBranches returns the newest release-1.x branches of the repo,
+// newest first.
+func releaseBranches(repo string) ([]string, error) {
+	var minors []int
+	next := fmt.Sprintf("https://api.github.com/repos/%s/branches?per_page=100", repo)
+	for next != "" {
+		var branches []struct {
+			Name string `json:"name"`
+		}
+		current := next
+		err := ghclient.WithRetry("branches of "+repo, func() (err error) {
+			next, err = ghclient.Get(current, &branches)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range branches {
+			if m := releaseBranch.FindStringSubmatch(b.Name); m != nil {
+				minor, _ := strconv.Atoi(m[1])
+				minors = append(minors, minor)
+			}
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(minors)))
+	if len(minors) > supportedReleases {
+		minors = minors[:supportedReleases]
+	}
+	var names []string
+	for _, minor := range minors {
+		names = append(names, fmt.Sprintf("release-1.%d", minor))
+	}
+	return names, nil
+}
+
+// cherryPicks is the open cherry picks to one branch.
+type cherryPicks struct {
+	Branch string
+	Query  string
+	Count  int
+	Oldest *issue
+}
+
+// searchOldest returns the number of items matching the query and the
+// oldest of them.
+func searchOldest(query string) (int, *issue, error) {
+	q := url.Values{}
+	q.Add("q", query)
+	q.Add("sort", "created")
+	q.Add("order", "asc")
+	q.Add("per_page", "1")
+
+	var result struct {
+		TotalCount int     `json:"total_count"`
+		Items      []issue `json:"items"`
+	}
+	err := ghclient.WithRetry(query, func() error {
+		_, err := ghclient.Get("https://api.github.com/search/issues?"+q.Encode(), &result)
+		return err
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("error for query %s: %w", query, err)
+	}
+	if len(result.Items) == 0 {
+		return result.TotalCount, nil, nil
+	}
+	return result.TotalCount, &result.Items[0], nil
+}
+
+func getCherryPicks(repo string) ([]cherryPicks, error) {
+	branches, err := releaseBranches(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	baseQuery := "repo:" + repo + " type:pr is:open label:sig/node "
+	var picks []cherryPicks
+	for _, b := range branches {
+		picks = append(picks, cherryPicks{Branch: b, Query: baseQuery + "base:" + b})
+	}
+	other := baseQuery + "-base:master"
+	for _, b := range branches {
+		other += " -base:" + b
+	}
+	picks = append(picks, cherryPicks{Branch: "other", Query: other})
+
+	for i := range picks {
+		picks[i].Count, picks[i].Oldest, err = searchOldest(picks[i].Query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return picks, nil
+}
+
+func cherryPicksRows(picks []cherryPicks, now time.Time) [][]interface{} {
+	rows := [][]interface{}{
+		{"updated " + now.UTC().Format("01/02/2006 15:04"), "open", "oldest (days)", "oldest PR", "search"},
+	}
+	for _, p := range picks {
+		var days, oldest interface{} = "", ""
+		if p.Oldest != nil {
+			days = int(now.Sub(p.Oldest.CreatedAt).Hours() / 24)
+			oldest = sheet.Link(p.Oldest.HTMLURL, fmt.Sprintf("#%d", p.Oldest.Number))
+		}
+		search := sheet.Link(ghclient.SearchURL(p.Query), "search")
+		rows = append(rows, []interface{}{p.Branch, p.Count, days, oldest, search})
+	}
+	return rows
+}
+
+// CherryPicks replaces the "Cherry picks" tab with the open cherry picks
+// per release branch.
+func CherryPicks(args []string) error {
+	fs := flag.NewFlagSet("cherrypicks", flag.ExitOnError)
+	repo := fs.String("repo", "kubernetes/kubernetes", "repository whose release branches are tracked")
+	fs.Parse(args)
+
+	picks, err := getCherryPicks(*repo)
+	if err != nil {
+		return err
+	}
+	for _, p := range picks {
+		metrics.Set("dashboard_column_count", float64(p.Count), "dashboard", cherryPicksSheet, "column", p.Branch)
+	}
+	if err := sheet.Replace(cherryPicksSheet, cherryPicksRows(picks, time.Now())); err != nil {
+		return err
+	}
+	logging.Logger.Info("wrote cherry picks", "branches", len(picks))
+	return nil
+}
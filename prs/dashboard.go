@@ -0,0 +1,76 @@
+package prs
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/charts"
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+)
+
+// The dashboard is a static site drawn from the snapshot store: a page per
+// column set with the chart of internal/charts, plus an index with all of
+// them.
+
+func writeDashboardPage(path string, page charts.Page) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := charts.Write(f, page); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Dashboard writes the static dashboard site.
+func Dashboard(args []string) error {
+	fs := flag.NewFlagSet("dashboard", flag.ExitOnError)
+	out := fs.String("out", "dashboard", "directory to write the site to")
+	fs.Parse(args)
+
+	sets, err := snapshots.Sets()
+	if err != nil {
+		return err
+	}
+	sort.Slice(sets, func(i, j int) bool { return charts.Title(sets[i]) < charts.Title(sets[j]) })
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+
+	generated := time.Now().UTC().Format("2006-01-02 15:04 MST")
+	var links []charts.Link
+	for _, set := range sets {
+		links = append(links, charts.Link{Title: charts.Title(set), URL: set + ".html"})
+	}
+
+	var all []charts.Chart
+	for _, set := range sets {
+		history, err := snapshots.Load(set)
+		if err != nil {
+			return fmt.Errorf("failed to load %s snapshots: %v", set, err)
+		}
+		c := charts.Build(set, history, nil)
+		c.URL = set + ".html"
+		all = append(all, c)
+
+		page := charts.Page{Title: c.Title, Index: "index.html", Sets: links, Charts: []charts.Chart{c}, Generated: generated}
+		if err := writeDashboardPage(filepath.Join(*out, set+".html"), page); err != nil {
+			return err
+		}
+	}
+
+	page := charts.Page{Title: "sig/node dashboards", Index: "index.html", Sets: links, Charts: all, Generated: generated}
+	if err := writeDashboardPage(filepath.Join(*out, "index.html"), page); err != nil {
+		return err
+	}
+
+	logging.Logger.Info("wrote dashboard", "dir", *out, "sets", sets)
+	return nil
+}
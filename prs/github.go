@@ -0,0 +1,54 @@
+package prs
+
+import (
+	"strings"
+	"time"
+)
+
+// issue is the subset of a search result item used by the reports.
+type issue struct {
+	Number        int       `json:"number"`
+	Title         string    `json:"title"`
+	HTMLURL       string    `json:"html_url"`
+	RepositoryURL string    `json:"repository_url"`
+	State         string    `json:"state"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	User          struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Assignees []struct {
+		Login string `json:"login"`
+	} `json:"assignees"`
+	PullRequest *struct {
+		MergedAt *time.Time `json:"merged_at"`
+	} `json:"pull_request"`
+}
+
+// repo returns owner/name of the repository the issue belongs to.
+func (i issue) repo() string {
+	return strings.TrimPrefix(i.RepositoryURL, "https://api.github.com/repos/")
+}
+
+func (i issue) hasLabel(name string) bool {
+	for _, l := range i.Labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// labelsWithPrefix returns the labels starting with prefix, e.g. "kind/".
+func (i issue) labelsWithPrefix(prefix string) []string {
+	var labels []string
+	for _, l := range i.Labels {
+		if strings.HasPrefix(l.Name, prefix) {
+			labels = append(labels, l.Name)
+		}
+	}
+	return labels
+}
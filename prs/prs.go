@@ -1,66 +1,32 @@
-package main
+// Package prs counts the open sig/node PRs and issues into the sig/node
+// spreadsheet and builds the reports drawn from them: stale items,
+// reviewers, milestone burndowns and the static dashboard.
+package prs
 
 import (
-	"encoding/json"
+	"flag"
 	"fmt"
-	"net/http"
-	"net/url"
 	"os"
-	"io"
+	"strings"
 	"time"
 
-	"golang.org/x/net/context"
-	"google.golang.org/api/option"
-	sheets "google.golang.org/api/sheets/v4"
+	"github.com/SergeyKanzhelev/github-queries/internal/alerts"
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/metrics"
+	"github.com/SergeyKanzhelev/github-queries/internal/sheet"
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
 )
 
-type prs struct {
-	TotalCount int `json:"total_count"`
+// tab is a sheet tab with the columns counted into each of its rows.
+type tab struct {
+	Sheet     string
+	Dashboard string
+	Columns   []snapshots.Column
 }
 
-type column struct {
-	ColumnName string
-	Labels     string
-}
-
-var apiRequestsCount = 0
-
-func getPRsCount(query string) (int, error) {
-	q := url.Values{}
-	q.Add("q", query)
-	q.Add("per_page", "1")
-
-	resp, err := http.Get("https://api.github.com/search/issues?" + q.Encode())
-
-	if apiRequestsCount == 9 {
-		time.Sleep(1 * time.Minute)
-		apiRequestsCount = 0
-	} else {
-		apiRequestsCount += 1
-	}
-
-	if err != nil {
-		return -1, fmt.Errorf("failed to get PRs: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		b, _ := io.ReadAll(resp.Body)
-		return -1, fmt.Errorf("status code is not 200: %v, %v", resp.StatusCode, string(b))
-	}
-
-	var result = prs{}
-
-	err = json.NewDecoder(resp.Body).Decode(&result)
-	if err != nil {
-		fmt.Errorf("Failed to parse JSON PRs: %v", err)
-		return -1, err
-	}
-
-	return result.TotalCount, nil
-}
-
-func getPRs() ([]interface{}, error) {
+// PRColumns are the columns of the PRs tab.
+func PRColumns() []snapshots.Column {
 	// see documentation
 	// https://developer.github.com/v3/search/#search-issues-and-pull-requests
 	// https://docs.github.com/en/github/searching-for-information-on-github/searching-issues-and-pull-requests
@@ -68,51 +34,36 @@ func getPRs() ([]interface{}, error) {
 	baseQuery := "repo:kubernetes/kubernetes type:pr is:open label:sig/node "
 	baseMasterQuery := baseQuery + "base:master "
 
-	columns := []column{
-		column{"total", baseQuery},
-		// column{"kind api-change", baseMasterQuery + "label:kind/api-change"},
-		column{"kind bug", baseMasterQuery + "label:kind/bug"},
-		column{"kind cleanup", baseMasterQuery + "label:kind/cleanup"},
-		column{"kind deprecation", baseMasterQuery + "label:kind/deprecation"},
-		column{"kind design", baseMasterQuery + "label:kind/design"},
-		column{"kind documentation", baseMasterQuery + "label:kind/documentation"},
-		column{"kind failing-test", baseMasterQuery + "label:kind/failing-test"},
-		column{"kind feature", baseMasterQuery + "label:kind/feature"},
-		column{"other", baseMasterQuery + "-label:kind/bug -label:kind/cleanup -label:kind/deprecation -label:kind/design -label:kind/documentation -label:kind/failing-test -label:kind/feature"},
-		column{"cherry picks", baseQuery + "-base:master"},
+	columns := []snapshots.Column{
+		{Name: "total", Query: baseQuery},
+		// {Name: "kind api-change", Query: baseMasterQuery + "label:kind/api-change"},
+		{Name: "kind bug", Query: baseMasterQuery + "label:kind/bug"},
+		{Name: "kind cleanup", Query: baseMasterQuery + "label:kind/cleanup"},
+		{Name: "kind deprecation", Query: baseMasterQuery + "label:kind/deprecation"},
+		{Name: "kind design", Query: baseMasterQuery + "label:kind/design"},
+		{Name: "kind documentation", Query: baseMasterQuery + "label:kind/documentation"},
+		{Name: "kind failing-test", Query: baseMasterQuery + "label:kind/failing-test"},
+		{Name: "kind feature", Query: baseMasterQuery + "label:kind/feature"},
+		{Name: "other", Query: baseMasterQuery + "-label:kind/bug -label:kind/cleanup -label:kind/deprecation -label:kind/design -label:kind/documentation -label:kind/failing-test -label:kind/feature"},
+		// broken down by release branch in the "Cherry picks" tab
+		{Name: "cherry picks", Query: baseQuery + "-base:master"},
 	}
 
 	// shrug: " -label:¯\\_(ツ)_/¯ "
 
-	header := "time"
-	result := []interface{}{}
-	result = append(result, fmt.Sprintf("%s", time.Now().Format("01/02/2006 15:04")))
-	//result = append(result, time.Now())
-	for _, v := range columns {
-		count, err := getPRsCount(v.Labels)
-		if err != nil {
-			return nil, fmt.Errorf("error for query %s: %v", v.Labels, err)
-		}
-		result = append(result, count)
-		header += fmt.Sprintf(", \"%s\"", v.ColumnName)
-
-		// q := url.Values{}
-		// q.Add("q", v.Labels)
-		// fmt.Printf("\"%s\", \"https://github.com/kubernetes/kubernetes/pulls?%s\"\n", v.ColumnName, q.Encode())
-	}
-
-	return result, nil
+	return columns
 }
 
-
-func getBugs() ([]interface{}, error) {
+// BugColumns are the columns of the Bugs tab, with the update windows
+// ending at dateNow.
+func BugColumns(dateNow time.Time) []snapshots.Column {
 	// see documentation
 	// https://developer.github.com/v3/search/#search-issues-and-pull-requests
 	// https://docs.github.com/en/github/searching-for-information-on-github/searching-issues-and-pull-requests
 
 	baseQuery := "repo:kubernetes/kubernetes is:issue is:open label:sig/node "
 
-	var dateNow = time.Now().UTC()
+	dateNow = dateNow.UTC()
 
 	var dateNowStr = dateNow.Format("2006-01-02T15:04:05-0700")
 	var dateRange2days = dateNow.AddDate(0, 0, -2).UTC().Format("2006-01-02T15:04:05-0700") + ".." + dateNowStr
@@ -120,96 +71,206 @@ func getBugs() ([]interface{}, error) {
 	var dateRange90days = dateNow.AddDate(0, 0, -90).UTC().Format("2006-01-02T15:04:05-0700") + ".." + dateNowStr
 	var dateOver90days = dateNow.AddDate(0, 0, -90).UTC().Format("2006-01-02T15:04:05-0700")
 
-	columns := []column{
-		column{"total", baseQuery},
-		column{"kind bug", baseQuery + "label:kind/bug"},
-		column{"kind cleanup", baseQuery + "label:kind/cleanup"},
-		column{"kind deprecation", baseQuery + "label:kind/deprecation"},
-		column{"kind documentation", baseQuery + "label:kind/documentation"},
-		column{"kind failing-test", baseQuery + "label:kind/failing-test"},
-		column{"kind feature", baseQuery + "label:kind/feature"},
-		column{"kind support", baseQuery + "label:kind/support"},
-		column{"kind flake", baseQuery + "label:kind/flake"},
-		column{"kind other", baseQuery + "-label:kind/bug -label:kind/cleanup -label:kind/deprecation -label:kind/design -label:kind/documentation -label:kind/failing-test -label:kind/feature -label:kind/support -label:kind/flake"},
-
-		column{"updated last 2 days", baseQuery + "updated:" + dateRange2days},
-		column{"updated last 10 days", baseQuery + "updated:" + dateRange10days},
-		column{"updated last 90 days", baseQuery + "updated:" + dateRange90days},
-		column{"updated over 90 days", baseQuery + "updated:<" + dateOver90days},
-	}
-
-	header := "time"
-	result := []interface{}{}
-	result = append(result, fmt.Sprintf("%s", time.Now().Format("01/02/2006 15:04")))
-	for _, v := range columns {
-		count, err := getPRsCount(v.Labels)
-		if err != nil {
-			return nil, fmt.Errorf("error for query %s: %v", v.Labels, err)
+	columns := []snapshots.Column{
+		{Name: "total", Query: baseQuery},
+		{Name: "kind bug", Query: baseQuery + "label:kind/bug"},
+		{Name: "kind cleanup", Query: baseQuery + "label:kind/cleanup"},
+		{Name: "kind deprecation", Query: baseQuery + "label:kind/deprecation"},
+		{Name: "kind documentation", Query: baseQuery + "label:kind/documentation"},
+		{Name: "kind failing-test", Query: baseQuery + "label:kind/failing-test"},
+		{Name: "kind feature", Query: baseQuery + "label:kind/feature"},
+		{Name: "kind support", Query: baseQuery + "label:kind/support"},
+		{Name: "kind flake", Query: baseQuery + "label:kind/flake"},
+		{Name: "kind other", Query: baseQuery + "-label:kind/bug -label:kind/cleanup -label:kind/deprecation -label:kind/design -label:kind/documentation -label:kind/failing-test -label:kind/feature -label:kind/support -label:kind/flake"},
+
+		{Name: "updated last 2 days", Query: baseQuery + "updated:" + dateRange2days},
+		{Name: "updated last 10 days", Query: baseQuery + "updated:" + dateRange10days},
+		{Name: "updated last 90 days", Query: baseQuery + "updated:" + dateRange90days},
+		{Name: "updated over 90 days", Query: baseQuery + "updated:<" + dateOver90days},
+	}
+
+	return columns
+}
+
+// countTab counts the columns of the tab missing from the checkpoint and
+// returns how many could still not be counted.
+func countTab(cp *checkpoint, t tab) int {
+	var todo []snapshots.Column
+	for _, v := range t.Columns {
+		if _, ok := cp.Counts[t.Sheet][v.Name]; !ok {
+			todo = append(todo, v)
 		}
-		result = append(result, count)
-		header += fmt.Sprintf(", \"%s\"", v.ColumnName)
 	}
 
-	return result, nil
+	missing := 0
+	counts, items, errs := ghclient.CountItems(snapshots.Queries(todo), snapshots.Listing())
+	for i, v := range todo {
+		if errs[i] != nil {
+			logging.Logger.Warn("failed to count column", "sheet", t.Sheet, "column", v.Name, "err", errs[i])
+			missing++
+			continue
+		}
+		cp.record(t.Sheet, v.Name, counts[i], items[i])
+	}
+	return missing
 }
 
+// row builds the sheet row of the tab from the checkpoint, followed by the
+// change of every column since the previous snapshot, a week earlier. Columns that could
+// not be counted, and their changes, are left empty. With links the counts link to
+// their searches, with the date windows of the run.
+func row(cp *checkpoint, t tab, previous *snapshots.Snapshot, links bool) []interface{} {
+	result := []interface{}{}
+	started := cp.Started.Format("01/02/2006 15:04")
+	if links {
+		started = sheet.Text(started)
+	}
+	result = append(result, started)
+	for _, v := range t.Columns {
+		count, ok := cp.Counts[t.Sheet][v.Name]
+		if !ok {
+			result = append(result, "")
+			continue
+		}
+		if links {
+			result = append(result, sheet.Link(ghclient.SearchURL(v.Query), count))
+		} else {
+			result = append(result, count)
+		}
+		metrics.Set("dashboard_column_count", float64(count), "dashboard", t.Dashboard, "column", v.Name)
+	}
 
-func writeToSheet(values []interface{}, sheet string) error {
-	// Service account based oauth2 two legged integration
-	ctx := context.Background()
-	srv, err := sheets.NewService(ctx, option.WithCredentialsFile("credentials.json"), option.WithScopes(sheets.SpreadsheetsScope))
-	if err != nil {
-		return fmt.Errorf("unable to retrieve Sheets client: %v", err)
+	for _, v := range t.Columns {
+		count, ok := cp.Counts[t.Sheet][v.Name]
+		prev, prevOk := previous.Count(v.Name)
+		if !ok || !prevOk {
+			result = append(result, "")
+			continue
+		}
+		result = append(result, count-prev)
 	}
 
-	// https://docs.google.com/spreadsheets/d/1VW5_Eq8MzswfDi9xEvfYyP8edF_Ny7MBANIsJXT3VGw/edit
-	spreadsheetId := "1VW5_Eq8MzswfDi9xEvfYyP8edF_Ny7MBANIsJXT3VGw"
-	readRange := sheet + "!A2:K"
-	resp, err := srv.Spreadsheets.Values.Get(spreadsheetId, readRange).Do()
-	if err != nil {
-		return fmt.Errorf("unable to retrieve data from sheet: %v", err)
+	return result
+}
+
+// set is the name of the tab in the snapshot store.
+func (t tab) set() string {
+	return strings.ToLower(t.Dashboard)
+}
+
+// snapshot returns the counts of the tab in the checkpoint.
+func (t tab) snapshot(cp *checkpoint) snapshots.Snapshot {
+	s := snapshots.Snapshot{Time: cp.Started}
+	for _, v := range t.Columns {
+		sc := snapshots.Column{Name: v.Name, Query: v.Query}
+		if count, ok := cp.Counts[t.Sheet][v.Name]; ok {
+			sc.Count = &count
+			sc.Items = cp.Items[t.Sheet][v.Name]
+		}
+		s.Columns = append(s.Columns, sc)
 	}
+	return s
+}
 
-	writeRange := fmt.Sprintf(sheet + "!A%d", len(resp.Values)+2)
+// deltaWindow is how far back the change of every column is computed.
+const deltaWindow = 7 * 24 * time.Hour
 
-	var vr sheets.ValueRange
+// tailRows is how many of the last sheet rows are searched for the previous
+// row: a week and a half of runs every 15 minutes.
+const tailRows = 1000
 
-	vr.Values = append(vr.Values, values)
+// previousSnapshot returns the counts of the tab a week before the run:
+// the last snapshot in the store taken by then or, without one, the last
+// row of the sheet written by then.
+func previousSnapshot(t tab, started time.Time) *snapshots.Snapshot {
+	before := started.Add(-deltaWindow)
+	s, err := snapshots.Before(t.set(), before)
+	if err != nil {
+		logging.Logger.Warn("failed to load previous snapshot", "sheet", t.Sheet, "err", err)
+	}
+	if s != nil {
+		return s
+	}
 
-	_, err = srv.Spreadsheets.Values.Update(spreadsheetId, writeRange, &vr).ValueInputOption("RAW").Do()
+	rows, err := sheet.Tail(t.Sheet, 2, tailRows)
 	if err != nil {
-		return fmt.Errorf("unable to write data to sheet: %v", err)
+		logging.Logger.Warn("failed to read previous row", "sheet", t.Sheet, "err", err)
+		return nil
+	}
+	for i := len(rows) - 1; i >= 0; i-- {
+		if len(rows[i]) == 0 {
+			continue
+		}
+		written, err := time.Parse("01/02/2006 15:04", fmt.Sprint(rows[i][0]))
+		if err == nil && !written.After(before) {
+			return snapshots.FromRow(rows[i], 1, t.Columns)
+		}
 	}
 	return nil
 }
 
-func main() {
+// Count appends the PRs and Bugs rows to the sheet and stores their
+// snapshots.
+func Count(args []string) error {
+	fs := flag.NewFlagSet("count", flag.ExitOnError)
+	fs.Parse(args)
 
-	results, err := getPRs()
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+	cp := loadCheckpoint(os.Getenv("CHECKPOINT_FILE"))
+	cp.Attempts++
+
+	tabs := []tab{
+		{"Sheet1", "PRs", PRColumns()},
+		{"Bugs", "Bugs", BugColumns(cp.Started)},
 	}
 
-	err = writeToSheet(results, "Sheet1")
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+	missing := 0
+	for _, t := range tabs {
+		missing += countTab(cp, t)
+		if err := cp.save(); err != nil {
+			logging.Logger.Warn("failed to save checkpoint", "err", err)
+		}
 	}
 
-	fmt.Printf("%v\n", results)
+	if missing > 0 {
+		if cp.Attempts < runAttempts() {
+			return fmt.Errorf("%d columns could not be counted (attempt %d of %d)", missing, cp.Attempts, runAttempts())
+		}
+		logging.Logger.Warn("out of attempts, leaving failed columns empty", "missing", missing, "attempts", cp.Attempts)
+	}
 
-	bugs, err := getBugs()
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+	links := sheet.Hyperlinks(false)
+	var rows []sheet.Row
+	previous := map[string]*snapshots.Snapshot{}
+	for _, t := range tabs {
+		if len(cp.Counts[t.Sheet]) == 0 {
+			return fmt.Errorf("no column of %s could be counted", t.Sheet)
+		}
+		previous[t.Sheet] = previousSnapshot(t, cp.Started)
+		rows = append(rows, sheet.Row{Sheet: t.Sheet, FirstRow: 2, Values: row(cp, t, previous[t.Sheet], links)})
 	}
 
-	err = writeToSheet(bugs, "Bugs")
+	valueInput := "RAW"
+	if links {
+		valueInput = "USER_ENTERED"
+	}
+	err := sheet.Append(valueInput, rows...)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		return err
+	}
+	cp.remove()
+
+	for _, t := range tabs {
+		s := t.snapshot(cp)
+		if err := snapshots.Append(t.set(), s); err != nil {
+			logging.Logger.Warn("failed to store snapshot", "sheet", t.Sheet, "err", err)
+		}
+		if err := alerts.Evaluate(t.set(), alerts.History(t.set(), s)); err != nil {
+			logging.Logger.Warn("failed to send alerts", "sheet", t.Sheet, "err", err)
+		}
 	}
 
-	fmt.Printf("%v\n", bugs)
+	for _, r := range rows {
+		logging.Logger.Info("wrote row", "sheet", r.Sheet, "values", r.Values)
+	}
+	return nil
 }
@@ -0,0 +1,271 @@
+package prs
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+	"github.com/SergeyKanzhelev/github-queries/internal/owners"
+	"github.com/SergeyKanzhelev/github-queries/internal/sheet"
+)
+
+// The reviewers report shows who the open sig/node PRs wait on: requested
+// reviewers, assignees and the approvers of the nearest OWNERS file of every
+// changed file, with the oldest pending review request of each person.
+
+type pullRequest struct {
+	RequestedReviewers []struct {
+		Login string `json:"login"`
+	} `json:"requested_reviewers"`
+	RequestedTeams []struct {
+		Slug string `json:"slug"`
+	} `json:"requested_teams"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+type pullFile struct {
+	Filename string `json:"filename"`
+}
+
+type issueEvent struct {
+	Event             string    `json:"event"`
+	CreatedAt         time.Time `json:"created_at"`
+	RequestedReviewer *struct {
+		Login string `json:"login"`
+	} `json:"requested_reviewer"`
+	RequestedTeam *struct {
+		Slug string `json:"slug"`
+	} `json:"requested_team"`
+}
+
+// workload is the open review load of one person.
+type workload struct {
+	Person    string
+	Requested int
+	Assigned  int
+	Approver  int
+
+	// OldestRequest is when the oldest pending review request of the
+	// person was made, on OldestPR.
+	OldestRequest time.Time
+	OldestPR      issue
+}
+
+// prReviewers is what a single PR waits on.
+type prReviewers struct {
+	PR        issue
+	Requested map[string]time.Time
+	Approvers map[string]bool
+}
+
+// Reviewers writes the reviewers report.
+func Reviewers(args []string) error {
+	fs := flag.NewFlagSet("reviewers", flag.ExitOnError)
+	query := fs.String("query", "repo:kubernetes/kubernetes type:pr is:open label:sig/node", "search query selecting the PRs")
+	sheetName := fs.String("sheet", "Reviewers", "sheet tab to write the table to; empty to only print markdown")
+	fs.Parse(args)
+
+	prs, _, err := ghclient.Search[issue](*query)
+	if err != nil {
+		return err
+	}
+
+	resolvers := map[string]*owners.Resolver{}
+	var resolversMu sync.Mutex
+	resolver := func(repo, ref string) *owners.Resolver {
+		resolversMu.Lock()
+		defer resolversMu.Unlock()
+		key := repo + "@" + ref
+		if resolvers[key] == nil {
+			resolvers[key] = owners.NewResolver(repo, ref)
+		}
+		return resolvers[key]
+	}
+
+	details := make([]prReviewers, len(prs))
+	errs := make([]error, len(prs))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < ghclient.Parallelism(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				details[i], errs[i] = getPRReviewers(prs[i], resolver)
+			}
+		}()
+	}
+	for i := range prs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("failed to get reviewers of %s: %v", prs[i].HTMLURL, err)
+		}
+	}
+
+	loads := aggregateWorkload(details)
+	writeWorkloadMarkdown(os.Stdout, len(prs), loads)
+
+	if *sheetName == "" {
+		return nil
+	}
+	return sheet.Replace(*sheetName, workloadRows(loads))
+}
+
+func getPRReviewers(pr issue, resolver func(repo, ref string) *owners.Resolver) (prReviewers, error) {
+	r := prReviewers{PR: pr, Requested: map[string]time.Time{}, Approvers: map[string]bool{}}
+	base := fmt.Sprintf("https://api.github.com/repos/%s", pr.repo())
+
+	var details pullRequest
+	if _, err := ghclient.Get(fmt.Sprintf("%s/pulls/%d", base, pr.Number), &details); err != nil {
+		return r, err
+	}
+
+	pending := map[string]bool{}
+	for _, u := range details.RequestedReviewers {
+		pending[u.Login] = true
+	}
+	for _, t := range details.RequestedTeams {
+		pending["team:"+t.Slug] = true
+	}
+
+	// Pending since the last time the review was requested.
+	next := fmt.Sprintf("%s/issues/%d/events?per_page=100", base, pr.Number)
+	for next != "" {
+		var events []issueEvent
+		var err error
+		if next, err = ghclient.Get(next, &events); err != nil {
+			return r, err
+		}
+		for _, e := range events {
+			if e.Event != "review_requested" {
+				continue
+			}
+			var who string
+			if e.RequestedReviewer != nil {
+				who = e.RequestedReviewer.Login
+			} else if e.RequestedTeam != nil {
+				who = "team:" + e.RequestedTeam.Slug
+			}
+			if pending[who] {
+				r.Requested[who] = e.CreatedAt
+			}
+		}
+	}
+	for who := range pending {
+		if _, ok := r.Requested[who]; !ok {
+			r.Requested[who] = pr.CreatedAt
+		}
+	}
+
+	repoOwners := resolver(pr.repo(), details.Base.Ref)
+	next = fmt.Sprintf("%s/pulls/%d/files?per_page=100", base, pr.Number)
+	for next != "" {
+		var files []pullFile
+		var err error
+		if next, err = ghclient.Get(next, &files); err != nil {
+			return r, err
+		}
+		for _, f := range files {
+			_, o, err := repoOwners.Nearest(f.Filename)
+			if err != nil {
+				return r, err
+			}
+			if o == nil {
+				continue
+			}
+			for _, a := range o.Approvers {
+				r.Approvers[a] = true
+			}
+		}
+	}
+
+	return r, nil
+}
+
+func aggregateWorkload(details []prReviewers) []*workload {
+	byPerson := map[string]*workload{}
+	get := func(person string) *workload {
+		if byPerson[person] == nil {
+			byPerson[person] = &workload{Person: person}
+		}
+		return byPerson[person]
+	}
+
+	for _, d := range details {
+		for person, since := range d.Requested {
+			w := get(person)
+			w.Requested++
+			if w.OldestRequest.IsZero() || since.Before(w.OldestRequest) {
+				w.OldestRequest = since
+				w.OldestPR = d.PR
+			}
+		}
+		for _, a := range d.PR.Assignees {
+			get(a.Login).Assigned++
+		}
+		for person := range d.Approvers {
+			get(person).Approver++
+		}
+	}
+
+	var loads []*workload
+	for _, w := range byPerson {
+		loads = append(loads, w)
+	}
+	sort.Slice(loads, func(i, j int) bool {
+		li, lj := loads[i].Requested+loads[i].Assigned, loads[j].Requested+loads[j].Assigned
+		if li != lj {
+			return li > lj
+		}
+		if loads[i].Approver != loads[j].Approver {
+			return loads[i].Approver > loads[j].Approver
+		}
+		return loads[i].Person < loads[j].Person
+	})
+	return loads
+}
+
+func daysSince(t time.Time) int {
+	return int(time.Since(t).Hours() / 24)
+}
+
+func writeWorkloadMarkdown(w io.Writer, prs int, loads []*workload) {
+	fmt.Fprintf(w, "## Review load of %d open PRs\n\n", prs)
+	fmt.Fprintf(w, "| Person | Review requested | Assigned | OWNERS approver | Oldest pending request |\n")
+	fmt.Fprintf(w, "|---|---|---|---|---|\n")
+	for _, l := range loads {
+		oldest := ""
+		if !l.OldestRequest.IsZero() {
+			oldest = fmt.Sprintf("%d days ([#%d](%s))", daysSince(l.OldestRequest), l.OldestPR.Number, l.OldestPR.HTMLURL)
+		}
+		fmt.Fprintf(w, "| %s | %d | %d | %d | %s |\n", l.Person, l.Requested, l.Assigned, l.Approver, oldest)
+	}
+}
+
+func workloadRows(loads []*workload) [][]interface{} {
+	rows := [][]interface{}{
+		{"updated " + time.Now().UTC().Format("01/02/2006 15:04"), "review requested", "assigned", "OWNERS approver", "oldest pending request (days)", "oldest pending PR"},
+	}
+	for _, l := range loads {
+		var days, link interface{} = "", ""
+		if !l.OldestRequest.IsZero() {
+			days = daysSince(l.OldestRequest)
+			link = sheet.Link(l.OldestPR.HTMLURL, fmt.Sprintf("#%d", l.OldestPR.Number))
+		}
+		rows = append(rows, []interface{}{l.Person, l.Requested, l.Assigned, l.Approver, days, link})
+	}
+	return rows
+}
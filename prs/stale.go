@@ -0,0 +1,175 @@
+package prs
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+)
+
+// The stale report lists sig/node items not updated for a number of days,
+// grouped by kind label and assignee. It can nudge them with a comment or
+// the lifecycle/stale label; actions are only logged unless -apply is set
+// and at most -max-actions items are touched per run.
+
+const staleLabel = "lifecycle/stale"
+
+const defaultNudge = "This item has not been updated in a while. " +
+	"Is it still relevant? Please update it or close it if it is no longer needed."
+
+type staleOptions struct {
+	Days       int
+	Query      string
+	Action     string
+	Comment    string
+	Apply      bool
+	MaxActions int
+}
+
+// Stale writes the stale report and nudges the stale items.
+func Stale(args []string) error {
+	var opts staleOptions
+	fs := flag.NewFlagSet("stale", flag.ExitOnError)
+	fs.IntVar(&opts.Days, "days", 90, "report items not updated for this many days")
+	fs.StringVar(&opts.Query, "query", "repo:kubernetes/kubernetes is:issue is:open label:sig/node", "search query selecting the items")
+	fs.StringVar(&opts.Action, "action", "none", "what to do with stale items: none, comment or label")
+	fs.StringVar(&opts.Comment, "comment", defaultNudge, "nudge comment posted by -action=comment")
+	fs.BoolVar(&opts.Apply, "apply", false, "perform the action; without it actions are only logged")
+	fs.IntVar(&opts.MaxActions, "max-actions", 20, "maximum number of items acted on per run")
+	fs.Parse(args)
+
+	if opts.Action != "none" && opts.Action != "comment" && opts.Action != "label" {
+		return fmt.Errorf("unknown action %q", opts.Action)
+	}
+	if opts.Apply && ghclient.Token() == "" {
+		return fmt.Errorf("ACCESS_TOKEN is needed for -apply")
+	}
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -opts.Days)
+	query := opts.Query + " updated:<" + cutoff.Format("2006-01-02T15:04:05-0700")
+	if opts.Action == "label" {
+		query += " -label:" + staleLabel
+	}
+
+	items, _, err := ghclient.Search[issue](query)
+	if err != nil {
+		return err
+	}
+
+	writeStaleReport(os.Stdout, opts.Days, items)
+
+	return actOnStale(opts, items)
+}
+
+// groupStale groups items by kind label, then by assignee. An item with
+// several kinds or assignees is listed under each of them.
+func groupStale(items []issue) map[string]map[string][]issue {
+	groups := map[string]map[string][]issue{}
+	for _, item := range items {
+		kinds := item.labelsWithPrefix("kind/")
+		if len(kinds) == 0 {
+			kinds = []string{"no kind"}
+		}
+		assignees := []string{"unassigned"}
+		if len(item.Assignees) > 0 {
+			assignees = nil
+			for _, a := range item.Assignees {
+				assignees = append(assignees, "@"+a.Login)
+			}
+		}
+
+		for _, kind := range kinds {
+			if groups[kind] == nil {
+				groups[kind] = map[string][]issue{}
+			}
+			for _, assignee := range assignees {
+				groups[kind][assignee] = append(groups[kind][assignee], item)
+			}
+		}
+	}
+	return groups
+}
+
+func sortedGroupKeys(m map[string][]issue) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeStaleReport(w io.Writer, days int, items []issue) {
+	fmt.Fprintf(w, "## Not updated for %d days: %d\n", days, len(items))
+
+	groups := groupStale(items)
+	var kinds []string
+	for k := range groups {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+
+	for _, kind := range kinds {
+		distinct := map[string]bool{}
+		for _, list := range groups[kind] {
+			for _, item := range list {
+				distinct[item.HTMLURL] = true
+			}
+		}
+		fmt.Fprintf(w, "\n### %s (%d)\n", kind, len(distinct))
+
+		for _, assignee := range sortedGroupKeys(groups[kind]) {
+			list := groups[kind][assignee]
+			sort.Slice(list, func(i, j int) bool { return list[i].UpdatedAt.Before(list[j].UpdatedAt) })
+
+			fmt.Fprintf(w, "\n#### %s (%d)\n\n", assignee, len(list))
+			for _, item := range list {
+				fmt.Fprintf(w, "- [#%d](%s) %s (updated %s)\n", item.Number, item.HTMLURL, item.Title, item.UpdatedAt.Format("2006-01-02"))
+			}
+		}
+	}
+}
+
+// actOnStale comments on or labels the oldest stale items first.
+func actOnStale(opts staleOptions, items []issue) error {
+	if opts.Action == "none" {
+		return nil
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].UpdatedAt.Before(items[j].UpdatedAt) })
+	if len(items) > opts.MaxActions {
+		logging.Logger.Warn("too many stale items, acting on the oldest only", "items", len(items), "max_actions", opts.MaxActions)
+		items = items[:opts.MaxActions]
+	}
+
+	for _, item := range items {
+		l := logging.Logger.With("action", opts.Action, "item", item.HTMLURL)
+		if !opts.Apply {
+			l.Info("dry run, not acting on stale item")
+			continue
+		}
+
+		var err error
+		switch opts.Action {
+		case "comment":
+			_, err = ghclient.Do(http.MethodPost,
+				fmt.Sprintf("https://api.github.com/repos/%s/issues/%d/comments", item.repo(), item.Number),
+				map[string]string{"body": opts.Comment}, nil)
+		case "label":
+			_, err = ghclient.Do(http.MethodPost,
+				fmt.Sprintf("https://api.github.com/repos/%s/issues/%d/labels", item.repo(), item.Number),
+				map[string][]string{"labels": {staleLabel}}, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s %s: %v", opts.Action, item.HTMLURL, err)
+		}
+		l.Info("acted on stale item")
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package testfailures
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Failing-test and flake issues are matched to the CI results by the job
+// and test names they mention, to find the failures nobody tracks and the
+// issues whose tests are green again.
+
+const failingTestQuery = "repo:kubernetes/kubernetes is:open is:issue label:sig/node label:kind/failing-test,kind/flake"
+
+var spaces = regexp.MustCompile(`\s+`)
+
+// normalizeTest drops the decorations test names get in JUnit files and
+// issue templates, so "Kubernetes e2e suite: [It] [sig-node] Pods ..." and
+// "[sig-node] Pods ..." match.
+func normalizeTest(name string) string {
+	name = strings.ToLower(name)
+	name = strings.TrimPrefix(name, "kubernetes e2e suite:")
+	name = strings.ReplaceAll(name, "[it]", "")
+	return strings.TrimSpace(spaces.ReplaceAllString(name, " "))
+}
+
+func isNameChar(c byte) bool {
+	return c == '-' || c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// mentionsJob tells whether text names the job, not a longer job it is the
+// prefix of.
+func mentionsJob(text, job string) bool {
+	for i := 0; ; {
+		j := strings.Index(text[i:], job)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(job)
+		if (start == 0 || !isNameChar(text[start-1])) && (end == len(text) || !isNameChar(text[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+// tracking is what one issue was matched to.
+type tracking struct {
+	Issue issue
+	Jobs  []string
+	Tests []*testResult
+}
+
+// untrackedJob is a job with failing or flaky tests that no issue
+// mentions.
+type untrackedJob struct {
+	Job   string
+	Tests []*testResult
+}
+
+// flakeReport correlates the issues with the CI results.
+type flakeReport struct {
+	Untracked []untrackedJob
+	// Green are the issues whose jobs and tests all passed.
+	Green []tracking
+}
+
+func correlate(issues []issue, results ciResults) flakeReport {
+	var report flakeReport
+	tracked := map[*testResult]bool{}
+	trackedJobs := map[string]bool{}
+
+	for _, is := range issues {
+		text := is.Title + "\n" + is.Body
+		normalized := normalizeTest(text)
+
+		t := tracking{Issue: is}
+		for _, job := range results.jobs() {
+			if mentionsJob(text, job) {
+				t.Jobs = append(t.Jobs, job)
+				trackedJobs[job] = true
+			}
+			for _, r := range results[job] {
+				if name := normalizeTest(r.Test); name != "" && strings.Contains(normalized, name) {
+					t.Tests = append(t.Tests, r)
+					tracked[r] = true
+				}
+			}
+		}
+		if len(t.Jobs) == 0 && len(t.Tests) == 0 {
+			continue
+		}
+
+		green := true
+		for _, job := range t.Jobs {
+			if len(results.failures(job)) > 0 {
+				green = false
+			}
+		}
+		for _, r := range t.Tests {
+			if !r.Green() {
+				green = false
+			}
+		}
+		if green {
+			report.Green = append(report.Green, t)
+		}
+	}
+
+	for _, job := range results.jobs() {
+		if trackedJobs[job] {
+			continue
+		}
+		var untracked []*testResult
+		for _, r := range results.failures(job) {
+			if !tracked[r] {
+				untracked = append(untracked, r)
+			}
+		}
+		if len(untracked) > 0 {
+			report.Untracked = append(report.Untracked, untrackedJob{job, untracked})
+		}
+	}
+	return report
+}
@@ -0,0 +1,129 @@
+package testfailures
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Failing-test issues name their jobs in Prow and testgrid links or as bare
+// job names, as the issue template asks. The jobs table groups the issues
+// by job together with the open PRs referencing them, so the CI subproject
+// sees which failing jobs are covered.
+
+const fixPRsQuery = "repo:kubernetes/kubernetes is:open is:pr label:sig/node"
+
+var (
+	// https://prow.k8s.io/view/gs/kubernetes-jenkins/logs/<job>/<build>
+	// https://prow.k8s.io/job-history/gs/kubernetes-jenkins/logs/<job>
+	// https://prow.k8s.io/view/gs/kubernetes-jenkins/pr-logs/pull/<repo>/<pr>/<job>/<build>
+	prowJobLink = regexp.MustCompile(`prow\.k8s\.io/(?:view|job-history)/gs/[^/\s]+/(?:logs|pr-logs/pull/[\w.-]+/\d+)/([\w.-]+)`)
+	// https://testgrid.k8s.io/<dashboard>#<tab>
+	testgridLink = regexp.MustCompile(`testgrid\.k8s\.io/([\w.-]+)(?:#([\w.%-]+))?`)
+	// ci-kubernetes-node-kubelet-serial, pull-kubernetes-node-e2e, ...;
+	// other jobs only in code spans, such as `ci-crio-cgroupv2-node-e2e`,
+	// since words like pull-request or ci-cd look the same
+	jobName     = regexp.MustCompile(`\b((?:ci|pull|periodic)-kubernetes-[\w.-]*[a-z0-9])\b`)
+	jobCodeSpan = regexp.MustCompile("`((?:ci|pull|periodic)-[a-z0-9][\\w.-]*[a-z0-9])`")
+
+	issueRef = regexp.MustCompile(`(?:kubernetes/kubernetes#|github\.com/kubernetes/kubernetes/issues/|(?:^|[^\w/])#)(\d+)`)
+)
+
+// jobRefs returns the jobs and testgrid dashboards the text refers to,
+// without duplicates.
+func jobRefs(text string) []string {
+	seen := map[string]bool{}
+	var refs []string
+	add := func(ref string) {
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	for _, m := range prowJobLink.FindAllStringSubmatch(text, -1) {
+		add(m[1])
+	}
+	for _, m := range testgridLink.FindAllStringSubmatch(text, -1) {
+		if m[2] != "" {
+			add(m[1] + "#" + m[2])
+		} else {
+			add(m[1])
+		}
+	}
+	// job names inside the links above are not referenced again on their own
+	bare := prowJobLink.ReplaceAllString(text, " ")
+	bare = testgridLink.ReplaceAllString(bare, " ")
+	for _, m := range jobName.FindAllStringSubmatch(bare, -1) {
+		add(m[1])
+	}
+	for _, m := range jobCodeSpan.FindAllStringSubmatch(bare, -1) {
+		add(m[1])
+	}
+	return refs
+}
+
+// referencedIssues returns the numbers of the kubernetes/kubernetes issues
+// the text refers to.
+func referencedIssues(text string) map[int]bool {
+	numbers := map[int]bool{}
+	for _, m := range issueRef.FindAllStringSubmatch(text, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			numbers[n] = true
+		}
+	}
+	return numbers
+}
+
+// jobCoverage is the open issues and fix PRs of one job.
+type jobCoverage struct {
+	Job    string
+	Issues []issue
+	PRs    []issue
+}
+
+func groupByJob(issues, prs []issue) []*jobCoverage {
+	fixes := map[int][]issue{}
+	for _, pr := range prs {
+		for n := range referencedIssues(pr.Title + "\n" + pr.Body) {
+			fixes[n] = append(fixes[n], pr)
+		}
+	}
+
+	byJob := map[string]*jobCoverage{}
+	for _, is := range issues {
+		refs := jobRefs(is.Title + "\n" + is.Body)
+		if len(refs) == 0 {
+			refs = []string{"(no job referenced)"}
+		}
+		for _, ref := range refs {
+			c := byJob[ref]
+			if c == nil {
+				c = &jobCoverage{Job: ref}
+				byJob[ref] = c
+			}
+			c.Issues = append(c.Issues, is)
+			for _, pr := range fixes[is.Number] {
+				if !containsIssue(c.PRs, pr) {
+					c.PRs = append(c.PRs, pr)
+				}
+			}
+		}
+	}
+
+	var jobs []*jobCoverage
+	for _, c := range byJob {
+		jobs = append(jobs, c)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Job < jobs[j].Job })
+	return jobs
+}
+
+func containsIssue(items []issue, item issue) bool {
+	for _, i := range items {
+		if i.Number == item.Number {
+			return true
+		}
+	}
+	return false
+}
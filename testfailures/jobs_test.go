@@ -0,0 +1,58 @@
+package testfailures
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestJobRefs(t *testing.T) {
+	for _, tc := range []struct {
+		name, text string
+		want       []string
+	}{
+		{"prow link", "see https://prow.k8s.io/view/gs/kubernetes-jenkins/logs/ci-kubernetes-node-kubelet-serial/123", []string{"ci-kubernetes-node-kubelet-serial"}},
+		{"prow job history", "https://prow.k8s.io/job-history/gs/kubernetes-jenkins/logs/ci-crio-cgroupv2-node-e2e", []string{"ci-crio-cgroupv2-node-e2e"}},
+		{"presubmit link", "https://prow.k8s.io/view/gs/kubernetes-jenkins/pr-logs/pull/kubernetes_kubernetes/1234/pull-kubernetes-node-e2e/55", []string{"pull-kubernetes-node-e2e"}},
+		{"testgrid tab", "https://testgrid.k8s.io/sig-node-release-blocking#node-kubelet-serial", []string{"sig-node-release-blocking#node-kubelet-serial"}},
+		{"testgrid dashboard", "https://testgrid.k8s.io/sig-node-cri-o", []string{"sig-node-cri-o"}},
+		{"bare name", "ci-kubernetes-node-e2e-containerd fails since Monday.", []string{"ci-kubernetes-node-e2e-containerd"}},
+		{"code span", "Failing job: `ci-crio-cgroupv1-node-e2e-eviction`", []string{"ci-crio-cgroupv1-node-e2e-eviction"}},
+		{"link not repeated", "ci-kubernetes-node-e2e https://prow.k8s.io/job-history/gs/kubernetes-jenkins/logs/ci-kubernetes-node-e2e", []string{"ci-kubernetes-node-e2e"}},
+		{"pull-request", "Opened a pull-request for the fix", nil},
+		{"ci-cd", "our ci-cd pipeline", nil},
+		{"periodic-sync", "the periodic-sync of pod status", nil},
+		{"other job outside code", "ci-crio-cgroupv1-node-e2e-eviction is red", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := jobRefs(tc.text); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("jobRefs(%q) = %q, want %q", tc.text, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReferencedIssues(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want string
+	}{
+		{"Fixes #123", "123"},
+		{"fixes kubernetes/kubernetes#45 and #67", "45,67"},
+		{"https://github.com/kubernetes/kubernetes/issues/89", "89"},
+		{"(#10)", "10"},
+		{"other/repo#11 and https://github.com/other/repo/issues/12", ""},
+		{"abc#13", ""},
+		{"no references", ""},
+	} {
+		var got []string
+		for n := range referencedIssues(tc.text) {
+			got = append(got, fmt.Sprint(n))
+		}
+		sort.Strings(got)
+		if s := strings.Join(got, ","); s != tc.want {
+			t.Errorf("referencedIssues(%q) = %q, want %q", tc.text, s, tc.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Package testfailures reports the sig/node failing-test issues, the PRs
+// fixing them and, given CI results, the flakes behind them.
+package testfailures
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/alerts"
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/metrics"
+	"github.com/SergeyKanzhelev/github-queries/internal/notify"
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+)
+
+// Columns are the counted columns of the report.
+func Columns() []snapshots.Column {
+	// see documentation
+	// https://developer.github.com/v3/search/#search-issues-and-pull-requests
+	// https://docs.github.com/en/github/searching-for-information-on-github/searching-issues-and-pull-requests
+
+	return []snapshots.Column{
+		{Name: "Test-infra sig/node: PRs", Query: "repo:kubernetes/test-infra is:pr is:open label:sig/node "},
+		{Name: "Test-infra sig/node: issues", Query: "repo:kubernetes/test-infra is:issue is:open label:sig/node "},
+		{Name: "k/k sig node area/test: PRs", Query: "repo:kubernetes/kubernetes is:open label:sig/node label:area/test is:pr "},
+		{Name: "k/k sig node area/test: PRs (approved)", Query: "repo:kubernetes/kubernetes is:open label:sig/node label:area/test is:pr label:approved"},
+		{Name: "k/k sig node area/test: issues", Query: "repo:kubernetes/kubernetes is:open label:sig/node label:area/test is:issue "},
+		{Name: "k/k sig node kind/failing-test: PRs", Query: "repo:kubernetes/kubernetes is:open label:sig/node is:pr label:kind/failing-test "},
+		{Name: "k/k sig node kind/failing-test: PRs (approved)", Query: "repo:kubernetes/kubernetes is:open label:sig/node is:pr label:kind/failing-test label:approved"},
+		{Name: "k/k sig node kind/failing-test", Query: "repo:kubernetes/kubernetes is:open label:sig/node is:issue label:kind/failing-test "},
+	}
+}
+
+func getPRs() ([]reportColumn, error) {
+	columns := Columns()
+
+	counts, items, errs := ghclient.CountItems(snapshots.Queries(columns), snapshots.Listing())
+	if err := ghclient.AllFailed(errs); err != nil {
+		return nil, err
+	}
+
+	var result []reportColumn
+	for i, v := range columns {
+		c := reportColumn{
+			Name:  v.Name,
+			Query: v.Query,
+			URL:   ghclient.SearchURL(v.Query),
+		}
+
+		if errs[i] != nil {
+			logging.Logger.Warn("failed to count column", "column", v.Name, "err", errs[i])
+			c.Failed = true
+		} else {
+			c.Count = counts[i]
+			c.items = items[i]
+			metrics.Set("dashboard_column_count", float64(c.Count), "dashboard", "Test failures", "column", v.Name)
+		}
+		result = append(result, c)
+	}
+
+	return result, nil
+}
+
+// issue is the subset of a search result item used by the reports.
+type issue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	Labels  []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+// snapshotSet is the name of the test failures columns in the snapshot
+// store.
+const snapshotSet = "testfailures"
+
+// testFailures builds the report: the counts with their values a week ago,
+// the failing-test issues by job and, given a results dir, their
+// correlation with the CI results in it.
+func testFailures(resultsDir string) (report, error) {
+	r := report{Time: time.Now().UTC()}
+
+	var err error
+	if r.Columns, err = getPRs(); err != nil {
+		return r, err
+	}
+
+	previous, err := snapshots.Before(snapshotSet, r.Time.Add(-7*24*time.Hour))
+	if err != nil {
+		logging.Logger.Warn("failed to load previous snapshot", "err", err)
+	}
+	s := snapshots.Snapshot{Time: r.Time}
+	for i, c := range r.Columns {
+		if count, ok := previous.Count(c.Name); ok {
+			r.Columns[i].Previous = &count
+		}
+		sc := snapshots.Column{Name: c.Name, Query: c.Query}
+		if !c.Failed {
+			count := c.Count
+			sc.Count = &count
+			sc.Items = c.items
+		}
+		s.Columns = append(s.Columns, sc)
+	}
+	for i, c := range r.Columns {
+		if entered, left, ok := s.Changes(previous, c.Name); ok {
+			r.Columns[i].Entered = changedItems(entered)
+			r.Columns[i].Left = changedItems(left)
+		}
+	}
+	if err := snapshots.Append(snapshotSet, s); err != nil {
+		logging.Logger.Warn("failed to store snapshot", "err", err)
+	}
+	if err := alerts.Evaluate(snapshotSet, alerts.History(snapshotSet, s)); err != nil {
+		logging.Logger.Warn("failed to send alerts", "err", err)
+	}
+
+	if r.Issues, _, err = ghclient.Search[issue](failingTestQuery); err != nil {
+		return r, err
+	}
+	if r.PRs, _, err = ghclient.Search[issue](fixPRsQuery); err != nil {
+		return r, err
+	}
+	r.Jobs = groupByJob(r.Issues, r.PRs)
+
+	if resultsDir == "" {
+		return r, nil
+	}
+	results, err := loadResults(resultsDir)
+	if err != nil {
+		return r, err
+	}
+	flakes := correlate(r.Issues, results)
+	r.Flakes = &flakes
+	return r, nil
+}
+
+// notifySummary posts the report, rendered with the slack template, to the
+// webhooks.
+func notifySummary(r report) error {
+	var b bytes.Buffer
+	if err := renderReport(&b, "slack", r); err != nil {
+		return err
+	}
+	return notify.Send(nil, b.String())
+}
+
+// Run prints the test failures report and posts its summary to the
+// NOTIFY_WEBHOOKS.
+func Run(args []string) error {
+	fs := flag.NewFlagSet("testfailures", flag.ExitOnError)
+	resultsDir := fs.String("results", "", "directory with a local copy of Prow or testgrid job results to correlate with the failing-test issues")
+	templateName := fs.String("template", "markdown", "report template: markdown, html or a template file; .html files are rendered with html/template")
+	fs.Parse(args)
+
+	r, err := testFailures(*resultsDir)
+	if err != nil {
+		return err
+	}
+	if err := renderReport(os.Stdout, *templateName, r); err != nil {
+		return err
+	}
+	if len(notify.Webhooks()) > 0 {
+		return notifySummary(r)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package testfailures
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+)
+
+// The report is rendered from a Go template: the built in markdown, html or
+// slack one, or a template file passed with -template. Files ending in .html are
+// rendered with html/template, everything else with text/template. The
+// templates get a report value.
+
+//go:embed templates
+var builtinTemplates embed.FS
+
+// reportColumn is a counted column. Previous is its count in the last
+// stored snapshot at least a week old, nil without one. Entered and Left are
+// the items that entered and left the column since then, when both runs
+// listed them.
+type reportColumn struct {
+	Name     string
+	Query    string
+	URL      string
+	Count    int
+	Failed   bool
+	Previous *int
+	Entered  []changedItem
+	Left     []changedItem
+
+	// items are the listed items behind Count, for the snapshot.
+	items []string
+}
+
+// changedItem is an issue or PR as owner/repo#number with its page.
+type changedItem struct {
+	Ref string
+	URL string
+}
+
+func changedItems(refs []string) []changedItem {
+	var items []changedItem
+	for _, ref := range refs {
+		items = append(items, changedItem{Ref: ref, URL: ghclient.ItemURL(ref)})
+	}
+	return items
+}
+
+// Delta is the change since the previous count, like "+5", or empty when
+// either count is missing.
+func (c reportColumn) Delta() string {
+	if c.Failed || c.Previous == nil {
+		return ""
+	}
+	return fmt.Sprintf("%+d", c.Count-*c.Previous)
+}
+
+type report struct {
+	Time    time.Time
+	Columns []reportColumn
+	// Issues are the open failing-test and flake issues, PRs the open
+	// sig/node PRs.
+	Issues []issue
+	PRs    []issue
+	Jobs   []*jobCoverage
+	// Flakes is nil unless CI results were given.
+	Flakes *flakeReport
+}
+
+// executor is what text/template and html/template templates have in
+// common.
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+func parseTemplate(name string) (executor, error) {
+	var b []byte
+	var err error
+	switch name {
+	case "markdown":
+		name = "templates/report.md"
+		b, err = builtinTemplates.ReadFile(name)
+	case "html":
+		name = "templates/report.html"
+		b, err = builtinTemplates.ReadFile(name)
+	case "slack":
+		name = "templates/report.slack"
+		b, err = builtinTemplates.ReadFile(name)
+	default:
+		b, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(name, ".html") {
+		return htmltemplate.New(filepath.Base(name)).Parse(string(b))
+	}
+	return template.New(filepath.Base(name)).Parse(string(b))
+}
+
+func renderReport(w io.Writer, name string, r report) error {
+	t, err := parseTemplate(name)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, r)
+}
@@ -0,0 +1,178 @@
+package testfailures
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CI results are read from a local copy of Prow job artifacts or testgrid
+// data: a directory with a subdirectory per job, holding JUnit XML files
+// (as in <job>/<build>/artifacts/junit_01.xml) or JSON files with a list of
+// {"job": ..., "test": ..., "failed": ...} runs. JSON runs without a job
+// belong to the job of their directory.
+
+// testResult is how a test did in the runs of a job.
+type testResult struct {
+	Job      string
+	Test     string
+	Runs     int
+	Failures int
+}
+
+func (r *testResult) Failing() bool {
+	return r.Failures > 0 && r.Failures == r.Runs
+}
+
+func (r *testResult) Flaky() bool {
+	return r.Failures > 0 && r.Failures < r.Runs
+}
+
+func (r *testResult) Green() bool {
+	return r.Failures == 0
+}
+
+type junitCase struct {
+	Name    string    `xml:"name,attr"`
+	Failure *struct{} `xml:"failure"`
+	Error   *struct{} `xml:"error"`
+	Skipped *struct{} `xml:"skipped"`
+}
+
+// junitSuite decodes both a <testsuites> and a <testsuite> root.
+type junitSuite struct {
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type jsonRun struct {
+	Job    string `json:"job"`
+	Test   string `json:"test"`
+	Failed bool   `json:"failed"`
+}
+
+// ciResults are the test results by job and test name.
+type ciResults map[string]map[string]*testResult
+
+func (c ciResults) record(job, test string, failed bool) {
+	if c[job] == nil {
+		c[job] = map[string]*testResult{}
+	}
+	r := c[job][test]
+	if r == nil {
+		r = &testResult{Job: job, Test: test}
+		c[job][test] = r
+	}
+	r.Runs++
+	if failed {
+		r.Failures++
+	}
+}
+
+// jobs returns the job names in order.
+func (c ciResults) jobs() []string {
+	var jobs []string
+	for job := range c {
+		jobs = append(jobs, job)
+	}
+	sort.Strings(jobs)
+	return jobs
+}
+
+// failures returns the failing and flaky tests of the job in order.
+func (c ciResults) failures(job string) []*testResult {
+	var failed []*testResult
+	for _, r := range c[job] {
+		if !r.Green() {
+			failed = append(failed, r)
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Test < failed[j].Test })
+	return failed
+}
+
+func loadResults(dir string) (ciResults, error) {
+	results := ciResults{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		job := strings.Split(filepath.ToSlash(rel), "/")[0]
+
+		switch filepath.Ext(path) {
+		case ".xml":
+			err = loadJUnit(results, job, path)
+		case ".json":
+			err = loadJSONRuns(results, job, path)
+		default:
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		return nil
+	})
+	return results, err
+}
+
+func loadJUnit(results ciResults, job, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var root junitSuite
+	if err := xml.NewDecoder(f).Decode(&root); err != nil {
+		return err
+	}
+
+	var walk func(s junitSuite)
+	walk = func(s junitSuite) {
+		for _, c := range s.Cases {
+			if c.Skipped != nil {
+				continue
+			}
+			results.record(job, c.Name, c.Failure != nil || c.Error != nil)
+		}
+		for _, child := range s.Suites {
+			walk(child)
+		}
+	}
+	walk(root)
+	return nil
+}
+
+func loadJSONRuns(results ciResults, job, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Prow also leaves started.json, finished.json and the like next to
+	// the results; only lists of runs are read.
+	if !strings.HasPrefix(strings.TrimSpace(string(b)), "[") {
+		return nil
+	}
+
+	var runs []jsonRun
+	if err := json.Unmarshal(b, &runs); err != nil {
+		return err
+	}
+	for _, r := range runs {
+		if r.Job == "" {
+			r.Job = job
+		}
+		results.record(r.Job, r.Test, r.Failed)
+	}
+	return nil
+}
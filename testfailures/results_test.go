@@ -0,0 +1,101 @@
+package testfailures
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadJUnit(t *testing.T) {
+	for _, tc := range []struct {
+		name, xml    string
+		runs, failed map[string]int
+		err          bool
+	}{
+		{"testsuite", `<testsuite><testcase name="a"/><testcase name="b"><failure/></testcase></testsuite>`,
+			map[string]int{"a": 1, "b": 1}, map[string]int{"b": 1}, false},
+		{"testsuites", `<testsuites><testsuite><testcase name="a"><error/></testcase></testsuite><testsuite><testcase name="a"/></testsuite></testsuites>`,
+			map[string]int{"a": 2}, map[string]int{"a": 1}, false},
+		{"nested", `<testsuites><testsuite><testsuite><testcase name="a"/></testsuite></testsuite></testsuites>`,
+			map[string]int{"a": 1}, nil, false},
+		{"skipped", `<testsuite><testcase name="a"><skipped/></testcase></testsuite>`, nil, nil, false},
+		{"not xml", `{"job": "j"}`, nil, nil, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "junit_01.xml")
+			write(t, path, tc.xml)
+
+			results := ciResults{}
+			err := loadJUnit(results, "job", path)
+			if (err != nil) != tc.err {
+				t.Fatalf("loadJUnit error %v, want error %v", err, tc.err)
+			}
+			if len(results["job"]) != len(tc.runs) {
+				t.Errorf("tests %v, want %v", results["job"], tc.runs)
+			}
+			for test, runs := range tc.runs {
+				r := results["job"][test]
+				if r == nil || r.Runs != runs || r.Failures != tc.failed[test] {
+					t.Errorf("%s: %+v, want %d runs and %d failures", test, r, runs, tc.failed[test])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadResults(t *testing.T) {
+	dir := t.TempDir()
+	write(t, filepath.Join(dir, "ci-kubernetes-node-e2e", "1", "artifacts", "junit_01.xml"), `<testsuite><testcase name="a"><failure/></testcase></testsuite>`)
+	write(t, filepath.Join(dir, "ci-kubernetes-node-e2e", "1", "finished.json"), `{"passed": false}`)
+	write(t, filepath.Join(dir, "ci-kubernetes-node-e2e", "runs.json"), `[{"test": "a", "failed": false}, {"job": "other", "test": "b", "failed": true}]`)
+	write(t, filepath.Join(dir, "ci-kubernetes-node-e2e", "build-log.txt"), "log")
+
+	results, err := loadResults(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := results["ci-kubernetes-node-e2e"]["a"]; r == nil || r.Runs != 2 || !r.Flaky() {
+		t.Errorf("a: %+v, want flaky in 2 runs", r)
+	}
+	if r := results["other"]["b"]; r == nil || !r.Failing() {
+		t.Errorf("b of the job named in the run: %+v, want failing", r)
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	results := ciResults{}
+	results.record("ci-kubernetes-node-e2e", "Kubernetes e2e suite: [It] [sig-node] Pods should run", true)
+	results.record("ci-kubernetes-node-e2e", "Kubernetes e2e suite: [It] [sig-node] Pods should run", false)
+	results.record("ci-kubernetes-node-e2e-serial", "[sig-node] Eviction should evict", true)
+	results.record("ci-kubernetes-node-e2e-serial", "[sig-node] Probes should probe", true)
+	results.record("ci-kubernetes-node-e2e-containerd", "[sig-node] Lifecycle should start", false)
+
+	issues := []issue{
+		// the flaky test, by name only
+		{Number: 1, Title: "[Flaky test] [sig-node] Pods should run"},
+		// the green containerd job, not ci-kubernetes-node-e2e, a prefix of it
+		{Number: 2, Title: "ci-kubernetes-node-e2e-containerd is failing", Body: "Which jobs: ci-kubernetes-node-e2e-containerd"},
+		// one of the two failures of the serial job
+		{Number: 3, Title: "[sig-node]  Eviction should   evict fails"},
+		{Number: 4, Title: "unrelated"},
+	}
+	report := correlate(issues, results)
+
+	if len(report.Green) != 1 || report.Green[0].Issue.Number != 2 {
+		t.Errorf("green issues %+v, want #2", report.Green)
+	}
+	if len(report.Untracked) != 1 || report.Untracked[0].Job != "ci-kubernetes-node-e2e-serial" ||
+		len(report.Untracked[0].Tests) != 1 || report.Untracked[0].Tests[0].Test != "[sig-node] Probes should probe" {
+		t.Errorf("untracked %+v, want the probes test of the serial job", report.Untracked)
+	}
+}
@@ -0,0 +1,198 @@
+package triage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+)
+
+// JSON API over the column sets and their snapshots, for tooling that would
+// otherwise scrape the sheets. Breaking changes get a new version prefix.
+//
+//	GET  /api/v1/sets                                    column sets and their queries
+//	GET  /api/v1/sets/<set>/latest                       last stored snapshot
+//	GET  /api/v1/sets/<set>/history?column=&from=&to=    counts of a column over time
+//	POST /api/v1/sets/<set>/recount                      count the set now
+//
+// from and to are RFC 3339 times or 2006-01-02 dates, both optional; a to
+// date includes that day.
+
+const apiPrefix = "/api/v1/"
+
+// minRecountAge keeps recounts from querying GitHub more than once a minute
+// per set; a recount sooner than that returns the last counts.
+const minRecountAge = time.Minute
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type historyPoint struct {
+	Time  time.Time `json:"time"`
+	Count int       `json:"count"`
+}
+
+type columnHistory struct {
+	Set    string         `json:"set"`
+	Column string         `json:"column"`
+	Query  string         `json:"query,omitempty"`
+	Points []historyPoint `json:"points"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Logger.Warn("failed to write response", "err", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, format string, a ...interface{}) {
+	writeJSON(w, status, apiError{Error: fmt.Sprintf(format, a...)})
+}
+
+// parseAPITime reads a from or to parameter; the zero time when it is empty.
+func parseAPITime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// historyOf returns the counts of the column between from and to, where zero
+// times leave the range open.
+func historyOf(history []snapshots.Snapshot, column string, from, to time.Time) columnHistory {
+	h := columnHistory{Column: column, Points: []historyPoint{}}
+	for _, s := range history {
+		if (!from.IsZero() && s.Time.Before(from)) || (!to.IsZero() && s.Time.After(to)) {
+			continue
+		}
+		for _, c := range s.Columns {
+			if c.Name == column {
+				h.Query = c.Query
+			}
+		}
+		if n, ok := s.Count(column); ok {
+			h.Points = append(h.Points, historyPoint{Time: s.Time, Count: n})
+		}
+	}
+	return h
+}
+
+func apiV1(w http.ResponseWriter, r *http.Request) {
+	sets, err := loadDashboardConfig()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/")
+	if parts[0] != "sets" || len(parts) > 3 {
+		writeAPIError(w, http.StatusNotFound, "no such endpoint %s", r.URL.Path)
+		return
+	}
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			writeAPIError(w, http.StatusMethodNotAllowed, "use GET")
+			return
+		}
+		writeJSON(w, http.StatusOK, sets)
+		return
+	}
+
+	var set *dashboardSet
+	for i := range sets {
+		if sets[i].Set == parts[1] {
+			set = &sets[i]
+		}
+	}
+	if set == nil {
+		writeAPIError(w, http.StatusNotFound, "no column set %q", parts[1])
+		return
+	}
+	if len(parts) == 2 {
+		writeJSON(w, http.StatusOK, set)
+		return
+	}
+
+	switch parts[2] {
+	case "latest":
+		apiLatest(w, r, set)
+	case "history":
+		apiHistory(w, r, set)
+	case "recount":
+		apiRecount(w, r, set)
+	default:
+		writeAPIError(w, http.StatusNotFound, "no such endpoint %s", r.URL.Path)
+	}
+}
+
+func apiLatest(w http.ResponseWriter, r *http.Request, set *dashboardSet) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	history, err := snapshots.Load(set.Set)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to load snapshots: %v", err)
+		return
+	}
+	if len(history) == 0 {
+		writeAPIError(w, http.StatusNotFound, "no snapshots of %s", set.Set)
+		return
+	}
+	writeJSON(w, http.StatusOK, history[len(history)-1])
+}
+
+func apiHistory(w http.ResponseWriter, r *http.Request, set *dashboardSet) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	q := r.URL.Query()
+	column := q.Get("column")
+	if column == "" {
+		writeAPIError(w, http.StatusBadRequest, "column is needed")
+		return
+	}
+	from, err := parseAPITime(q.Get("from"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad from: %v", err)
+		return
+	}
+	to, err := parseAPITime(q.Get("to"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad to: %v", err)
+		return
+	}
+	if len(q.Get("to")) == len("2006-01-02") {
+		to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	history, err := snapshots.Load(set.Set)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "failed to load snapshots: %v", err)
+		return
+	}
+	h := historyOf(history, column, from, to)
+	h.Set = set.Set
+	writeJSON(w, http.StatusOK, h)
+}
+
+// apiRecount counts the set now and updates the dashboard cache. The store
+// is left alone; snapshots are only written by the cron jobs.
+func apiRecount(w http.ResponseWriter, r *http.Request, set *dashboardSet) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	writeJSON(w, http.StatusOK, counts.get(r.Context(), *set, minRecountAge))
+}
@@ -0,0 +1,67 @@
+package triage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+)
+
+func TestParseAPITime(t *testing.T) {
+	for _, tc := range []struct {
+		v    string
+		want time.Time
+		err  bool
+	}{
+		{"", time.Time{}, false},
+		{"2026-10-19", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), false},
+		{"2026-10-19T10:30:00Z", time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC), false},
+		{"2026-10-19T12:30:00+02:00", time.Date(2026, 10, 19, 10, 30, 0, 0, time.UTC), false},
+		{"19/10/2026", time.Time{}, true},
+		{"2026-10-19 10:30", time.Time{}, true},
+	} {
+		got, err := parseAPITime(tc.v)
+		if !got.Equal(tc.want) || (err != nil) != tc.err {
+			t.Errorf("parseAPITime(%q) = %v, %v; want %v, error %v", tc.v, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func TestHistoryOf(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+	var history []snapshots.Snapshot
+	for d := 1; d <= 4; d++ {
+		n := d * 10
+		c := snapshots.Column{Name: "c", Query: "q", Count: &n}
+		if d == 3 {
+			c.Count = nil // not counted
+		}
+		history = append(history, snapshots.Snapshot{Time: day(d), Columns: []snapshots.Column{c, {Name: "other"}}})
+	}
+
+	for _, tc := range []struct {
+		name     string
+		column   string
+		from, to time.Time
+		query    string
+		points   string
+	}{
+		{"all", "c", time.Time{}, time.Time{}, "q", "[1:10 2:20 4:40]"},
+		{"from", "c", day(2), time.Time{}, "q", "[2:20 4:40]"},
+		{"to", "c", time.Time{}, day(2), "q", "[1:10 2:20]"},
+		{"range without counts", "c", day(3), day(3), "q", "[]"},
+		{"unknown column", "nope", time.Time{}, time.Time{}, "", "[]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := historyOf(history, tc.column, tc.from, tc.to)
+			var points []string
+			for _, p := range h.Points {
+				points = append(points, fmt.Sprintf("%d:%d", p.Time.Day(), p.Count))
+			}
+			if h.Points == nil || h.Column != tc.column || h.Query != tc.query || fmt.Sprint(points) != tc.points {
+				t.Errorf("historyOf = %+v, want query %q and points %s", h, tc.query, tc.points)
+			}
+		})
+	}
+}
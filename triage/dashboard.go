@@ -0,0 +1,186 @@
+package triage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/charts"
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/metrics"
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+	"github.com/SergeyKanzhelev/github-queries/prs"
+	"github.com/SergeyKanzhelev/github-queries/testfailures"
+	"github.com/SergeyKanzhelev/github-queries/weekly"
+)
+
+// Live dashboards: the current counts of every column set of count, weekly
+// and testfailures (or of the sets in the file in DASHBOARDS_CONFIG), each linking to
+// its GitHub search, over a line chart of the set's history from the
+// snapshot store. Counts are kept in memory for DASHBOARD_CACHE_TTL, so page
+// loads only reach GitHub when the counts of a set are older than that.
+
+type dashboardSet struct {
+	Set     string             `json:"set"`
+	Title   string             `json:"title,omitempty"`
+	Columns []snapshots.Column `json:"columns"`
+}
+
+// defaultDashboardSets are the columns of count, weekly and testfailures
+// under their set names in the snapshot store, with their date windows
+// ending now.
+func defaultDashboardSets(now time.Time) []dashboardSet {
+	return []dashboardSet{
+		{Set: "prs", Columns: prs.PRColumns()},
+		{Set: "bugs", Columns: prs.BugColumns(now)},
+		{Set: "weekly", Columns: weekly.Columns()},
+		{Set: "testfailures", Columns: testfailures.Columns()},
+	}
+}
+
+// loadDashboardConfig reads DASHBOARDS_CONFIG, or generates the default
+// sets when it is not set.
+func loadDashboardConfig() ([]dashboardSet, error) {
+	sets := defaultDashboardSets(time.Now())
+	if path := os.Getenv("DASHBOARDS_CONFIG"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		sets = nil
+		if err := json.Unmarshal(b, &sets); err != nil {
+			return nil, fmt.Errorf("failed to parse dashboards config: %w", err)
+		}
+	}
+
+	for i, s := range sets {
+		if s.Title == "" {
+			sets[i].Title = charts.Title(s.Set)
+		}
+	}
+	return sets, nil
+}
+
+const defaultDashboardCacheTTL = 10 * time.Minute
+
+// countCache keeps the last counts of every column set. Each set has its own
+// lock, so concurrent page loads wait for a single recount instead of all
+// querying GitHub.
+type countCache struct {
+	TTL time.Duration
+
+	mu   sync.Mutex
+	sets map[string]*cachedCounts
+}
+
+type cachedCounts struct {
+	mu      sync.Mutex
+	counted snapshots.Snapshot
+}
+
+func newCountCache() *countCache {
+	ttl := defaultDashboardCacheTTL
+	if v := os.Getenv("DASHBOARD_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			logging.Logger.Warn("ignoring bad DASHBOARD_CACHE_TTL", "value", v, "err", err)
+		} else {
+			ttl = d
+		}
+	}
+	return &countCache{TTL: ttl, sets: map[string]*cachedCounts{}}
+}
+
+var counts = newCountCache()
+
+// get returns the counts of the set, counting them again when the cached
+// ones are older than maxAge.
+func (c *countCache) get(ctx context.Context, set dashboardSet, maxAge time.Duration) snapshots.Snapshot {
+	c.mu.Lock()
+	cached, ok := c.sets[set.Set]
+	if !ok {
+		cached = &cachedCounts{}
+		c.sets[set.Set] = cached
+	}
+	c.mu.Unlock()
+
+	cached.mu.Lock()
+	defer cached.mu.Unlock()
+	if !cached.counted.Time.IsZero() && time.Since(cached.counted.Time) < maxAge {
+		metrics.Add("dashboard_count_cache_total", 1, "set", set.Set, "result", "hit")
+		return cached.counted
+	}
+	metrics.Add("dashboard_count_cache_total", 1, "set", set.Set, "result", "miss")
+	cached.counted = countSet(ctx, set)
+	return cached.counted
+}
+
+// countSet counts every column of the set. Columns that fail to count have
+// no count, like in the snapshots of the cron jobs.
+func countSet(ctx context.Context, set dashboardSet) snapshots.Snapshot {
+	l := logging.From(ctx)
+	s := snapshots.Snapshot{Time: time.Now().UTC()}
+	counts, errs := ghclient.Counts(snapshots.Queries(set.Columns))
+	for i, col := range set.Columns {
+		sc := snapshots.Column{Name: col.Name, Query: col.Query}
+		if errs[i] != nil {
+			l.Warn("failed to count column", "set", set.Set, "column", col.Name, "err", errs[i])
+		} else {
+			sc.Count = &counts[i]
+		}
+		s.Columns = append(s.Columns, sc)
+	}
+	return s
+}
+
+// dashboards serves /dashboards with every column set and
+// /dashboards/<set> with one of them.
+func dashboards(w http.ResponseWriter, r *http.Request) {
+	sets, err := loadDashboardConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("something went wrong: %q", err), http.StatusInternalServerError)
+		return
+	}
+
+	page := charts.Page{Title: "sig/node dashboards", Index: "/dashboards"}
+	for _, s := range sets {
+		page.Sets = append(page.Sets, charts.Link{Title: s.Title, URL: "/dashboards/" + s.Set})
+	}
+	shown := sets
+	if name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/dashboards"), "/"); name != "" {
+		shown = nil
+		for _, s := range sets {
+			if s.Set == name {
+				shown = []dashboardSet{s}
+				page.Title = s.Title
+			}
+		}
+		if shown == nil {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	for _, s := range shown {
+		history, err := snapshots.Load(s.Set)
+		if err != nil {
+			logging.From(ctx).Warn("failed to load snapshot history", "set", s.Set, "err", err)
+		}
+		current := counts.get(ctx, s, counts.TTL)
+		c := charts.Build(s.Set, history, &current)
+		c.Title, c.URL = s.Title, "/dashboards/"+s.Set
+		page.Charts = append(page.Charts, c)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := charts.Write(w, page); err != nil {
+		logging.From(ctx).Error("failed to render dashboard", "err", err)
+	}
+}
@@ -0,0 +1,250 @@
+package triage
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/metrics"
+	"github.com/SergeyKanzhelev/github-queries/internal/owners"
+	"github.com/google/go-github/v40/github"
+)
+
+// Routing of PRs to subprojects by the OWNERS files of their changed files,
+// so PRs without area labels still reach the right project column. A PR
+// touching files owned by several subprojects is routed to each of them.
+
+type subproject struct {
+	Name string `json:"name"`
+	// Owners are the directories whose OWNERS files belong to the
+	// subproject; subdirectories are included.
+	Owners  []string `json:"owners"`
+	Label   string   `json:"label"`
+	Project int      `json:"project"`
+	Column  string   `json:"column"`
+}
+
+type routingConfig struct {
+	Org         string       `json:"org"`
+	Query       string       `json:"query"`
+	Subprojects []subproject `json:"subprojects"`
+}
+
+//go:embed subprojects.json
+var defaultRoutingConfig []byte
+
+// loadRoutingConfig reads SUBPROJECTS_CONFIG, or the built in subprojects.json
+// when it is not set.
+func loadRoutingConfig() (*routingConfig, error) {
+	b := defaultRoutingConfig
+	if path := os.Getenv("SUBPROJECTS_CONFIG"); path != "" {
+		var err error
+		if b, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var cfg routingConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse subprojects config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// subprojectOf returns the subproject owning the OWNERS directory, picking
+// the most specific owners entry when several match.
+func (cfg *routingConfig) subprojectOf(dir string) *subproject {
+	var best *subproject
+	bestLen := -1
+	for i, s := range cfg.Subprojects {
+		for _, o := range s.Owners {
+			o = strings.Trim(o, "/")
+			if (dir == o || strings.HasPrefix(dir, o+"/")) && len(o) > bestLen {
+				best, bestLen = &cfg.Subprojects[i], len(o)
+			}
+		}
+	}
+	return best
+}
+
+// routePR returns the subprojects owning the files changed by the PR.
+func routePR(ctx context.Context, client *github.Client, cfg *routingConfig, resolver *owners.Resolver, owner, repo string, number int) ([]*subproject, error) {
+	seen := map[string]bool{}
+	var routed []*subproject
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		files, resp, err := client.PullRequests.ListFiles(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("PullRequests.ListFiles returned error: %w", err)
+		}
+
+		for _, f := range files {
+			dir, _, err := resolver.Nearest(f.GetFilename())
+			if err != nil {
+				return nil, err
+			}
+			if s := cfg.subprojectOf(dir); s != nil && !seen[s.Name] {
+				seen[s.Name] = true
+				routed = append(routed, s)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return routed, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func alreadyInProject(err error) bool {
+	var ge *github.ErrorResponse
+	return errors.As(err, &ge) && ge.Response != nil && ge.Response.StatusCode == http.StatusUnprocessableEntity
+}
+
+// searchPRs returns every PR matched by the query.
+func searchPRs(ctx context.Context, client *github.Client, query string) ([]*github.Issue, error) {
+	var issues []*github.Issue
+	opts := &github.SearchOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		result, resp, err := client.Search.Issues(ctx, query, opts)
+		if err != nil {
+			return nil, fmt.Errorf("Search.Issues returned error: %w", err)
+		}
+		issues = append(issues, result.Issues...)
+
+		if resp.NextPage == 0 {
+			return issues, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// routePRs routes every PR matched by the config query. With dryRun the
+// routes are only reported. All PRs are searched before any is labelled:
+// the query usually excludes the subproject labels, so labelling while
+// paging would shift the later pages and skip PRs.
+func routePRs(ctx context.Context, client *github.Client, cfg *routingConfig, dryRun bool) ([]string, error) {
+	l := logging.From(ctx)
+	resolvers := map[string]*owners.Resolver{}
+	columns := map[string]int64{}
+	var report []string
+
+	found, err := searchPRs(ctx, client, cfg.Query)
+	if err != nil {
+		return report, err
+	}
+
+	for _, pr := range found {
+		parts := strings.Split(strings.TrimPrefix(pr.GetRepositoryURL(), "https://api.github.com/repos/"), "/")
+		if len(parts) != 2 {
+			continue
+		}
+		owner, repo := parts[0], parts[1]
+
+		key := owner + "/" + repo
+		if resolvers[key] == nil {
+			resolvers[key] = owners.NewResolver(key, "master")
+		}
+
+		subprojects, err := routePR(ctx, client, cfg, resolvers[key], owner, repo, pr.GetNumber())
+		if err != nil {
+			return report, err
+		}
+
+		for _, s := range subprojects {
+			report = append(report, fmt.Sprintf("%s -> %s", pr.GetHTMLURL(), s.Name))
+			l.Info("routing PR", "pr", pr.GetHTMLURL(), "subproject", s.Name, "dry_run", dryRun)
+			if dryRun {
+				continue
+			}
+
+			if s.Label != "" && !hasLabel(pr, s.Label) {
+				_, _, err := client.Issues.AddLabelsToIssue(ctx, owner, repo, pr.GetNumber(), []string{s.Label})
+				if err != nil {
+					return report, fmt.Errorf("Issues.AddLabelsToIssue returned error: %w", err)
+				}
+			}
+
+			if s.Project != 0 {
+				columnKey := fmt.Sprintf("%d/%s", s.Project, s.Column)
+				if _, ok := columns[columnKey]; !ok {
+					id, err := getColumnID(ctx, client, cfg.Org, s.Project, s.Column)
+					if err != nil {
+						return report, err
+					}
+					columns[columnKey] = id
+				}
+
+				_, _, err := client.Projects.CreateProjectCard(ctx, columns[columnKey], &github.ProjectCardOptions{
+					ContentID:   pr.GetID(),
+					ContentType: "Issue",
+				})
+				if alreadyInProject(err) {
+					l.Debug("PR is already in the project", "pr", pr.GetHTMLURL(), "project", s.Project)
+				} else if err != nil {
+					return report, fmt.Errorf("Projects.CreateProjectCard returned error: %w", err)
+				}
+			}
+
+			metrics.Add("triage_prs_routed_total", 1, "subproject", s.Name)
+		}
+	}
+	return report, nil
+}
+
+func hasLabel(issue *github.Issue, name string) bool {
+	for _, l := range issue.Labels {
+		if l.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nodePRsRoute routes the PRs; add ?dry_run=1 to only see the routes. It
+// changes labels and project cards, so it only takes POST requests with
+// the ROUTE_TOKEN shared secret as a bearer token, and is off without one.
+func nodePRsRoute(w http.ResponseWriter, r *http.Request) {
+	token := os.Getenv("ROUTE_TOKEN")
+	if token == "" {
+		http.Error(w, "routing is off, set ROUTE_TOKEN to turn it on", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "routing takes a POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+		http.Error(w, "wrong or missing route token", http.StatusUnauthorized)
+		return
+	}
+
+	cfg, err := loadRoutingConfig()
+	if err != nil {
+		fmt.Fprintf(w, "something went wrong: %q", err)
+		return
+	}
+
+	ctx, client := newGitHubClient(r)
+	dryRun := r.URL.Query().Get("dry_run") != ""
+
+	report, err := routePRs(ctx, client, cfg, dryRun)
+	for _, line := range report {
+		fmt.Fprintln(w, line)
+	}
+	if err != nil {
+		fmt.Fprintf(w, "something went wrong: %q", err)
+		return
+	}
+
+	fmt.Fprintf(w, "%d routes to subprojects\n", len(report))
+}
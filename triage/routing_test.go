@@ -0,0 +1,62 @@
+package triage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSubprojectOf(t *testing.T) {
+	cfg := &routingConfig{Subprojects: []subproject{
+		{Name: "kubelet", Owners: []string{"pkg/kubelet"}},
+		{Name: "resource-management", Owners: []string{"/pkg/kubelet/cm/", "pkg/kubelet/eviction"}},
+		{Name: "node-e2e", Owners: []string{"test/e2e_node"}},
+	}}
+
+	for _, tc := range []struct {
+		dir, want string
+	}{
+		{"pkg/kubelet", "kubelet"},
+		{"pkg/kubelet/kuberuntime", "kubelet"},
+		{"pkg/kubelet/cm", "resource-management"},
+		{"pkg/kubelet/cm/devicemanager", "resource-management"},
+		{"pkg/kubelet/eviction", "resource-management"},
+		{"pkg/kubeletconfig", ""},
+		{"test/e2e_node", "node-e2e"},
+		{"pkg/proxy", ""},
+		{"", ""},
+	} {
+		var got string
+		if s := cfg.subprojectOf(tc.dir); s != nil {
+			got = s.Name
+		}
+		if got != tc.want {
+			t.Errorf("subprojectOf(%q) = %q, want %q", tc.dir, got, tc.want)
+		}
+	}
+}
+
+func TestRouteNeedsPOSTAndToken(t *testing.T) {
+	for _, tc := range []struct {
+		name, token, method, auth string
+		want                      int
+	}{
+		{"off without a token", "", http.MethodPost, "Bearer ", http.StatusNotFound},
+		{"GET", "secret", http.MethodGet, "Bearer secret", http.StatusMethodNotAllowed},
+		{"no token", "secret", http.MethodPost, "", http.StatusUnauthorized},
+		{"wrong token", "secret", http.MethodPost, "Bearer guess", http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ROUTE_TOKEN", tc.token)
+			req := httptest.NewRequest(tc.method, "/triage/node-prs/route?dry_run=1", nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			w := httptest.NewRecorder()
+			nodePRsRoute(w, req)
+			if w.Code != tc.want {
+				t.Errorf("status %d, want %d", w.Code, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+// Package triage is the sig/node triage server: it adds new sig/node PRs
+// and issues to the triage columns of the sig/node projects, routes PRs to
+// subprojects, and serves the live dashboards and the JSON API.
+package triage
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/metrics"
+	"github.com/google/go-github/v40/github"
+)
+
+// Serve runs the web server on PORT.
+func Serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Parse(args)
+
+	if ghclient.Token() == "" {
+		return errors.New("ACCESS_TOKEN is needed")
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	logging.Logger.Info("starting the web server", "port", port)
+
+	http.HandleFunc("/", logging.WithRequestID(landing))
+	http.HandleFunc("/triage", logging.WithRequestID(landing))
+
+	http.HandleFunc("/triage/node-prs", logging.WithRequestID(nodePRsIndex))
+	http.HandleFunc("/triage/node-prs/do", logging.WithRequestID(nodePRsDo))
+	http.HandleFunc("/triage/node-prs/route", logging.WithRequestID(nodePRsRoute))
+
+	http.HandleFunc("/dashboards", logging.WithRequestID(dashboards))
+	http.HandleFunc("/dashboards/", logging.WithRequestID(dashboards))
+	http.HandleFunc(apiPrefix, logging.WithRequestID(apiV1))
+	http.HandleFunc("/metrics", metrics.Handler)
+
+	return http.ListenAndServe(":"+port, nil)
+}
+
+// Triage adds the sig/node items to the triage columns once, the same as
+// /triage/node-prs/do, and with -route routes the PRs to subprojects.
+func Triage(args []string) error {
+	fs := flag.NewFlagSet("triage", flag.ExitOnError)
+	route := fs.Bool("route", false, "also route the PRs to subprojects by their OWNERS files")
+	dryRun := fs.Bool("dry-run", false, "only log the routes of -route")
+	fs.Parse(args)
+
+	token := ghclient.Token()
+	if token == "" {
+		return errors.New("ACCESS_TOKEN is needed")
+	}
+	ctx := context.Background()
+	client := ghclient.New(ctx, token)
+
+	if err := addToTriage(ctx, client); err != nil {
+		return err
+	}
+	if !*route {
+		return nil
+	}
+
+	cfg, err := loadRoutingConfig()
+	if err != nil {
+		return err
+	}
+	report, err := routePRs(ctx, client, cfg, *dryRun)
+	logging.Logger.Info("routed PRs", "routes", len(report))
+	return err
+}
+
+func landing(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "Wrong page %s", r.URL.Path[1:])
+}
+
+func nodePRsIndex(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "Hello, this is a node PRs triage page %s", r.URL.Path[1:])
+}
+
+// newGitHubClient returns a GitHub client authenticated with the access
+// token and a context carrying the request scoped logger.
+func newGitHubClient(r *http.Request) (context.Context, *github.Client) {
+	ctx := logging.NewContext(context.Background(), logging.From(r.Context()))
+	return ctx, ghclient.New(ctx, ghclient.Token())
+}
+
+// triageRules are the searches whose items get a card in the Triage column
+// of each project.
+var triageRules = []struct {
+	Project int
+	Queries []string
+}{
+	{43, []string{
+		"is:pr is:open label:sig/node -project:kubernetes/43 repo:kubernetes/test-infra",
+		"is:open label:sig/node+-project:kubernetes/43+repo:kubernetes/test-infra",
+		"is:open label:sig/node is:pr label:area/test -project:kubernetes/43 repo:kubernetes/kubernetes",
+		"is:issue is:open label:sig/node  label:area/test -project:kubernetes/43 repo:kubernetes/kubernetes",
+		"is:open label:sig/node is:pr label:kind/failing-test -project:kubernetes/43 repo:kubernetes/kubernetes",
+		"is:issue is:open label:sig/node label:kind/failing-test -project:kubernetes/43 repo:kubernetes/kubernetes",
+	}},
+	{59, []string{
+		"is:open label:sig/node is:issue label:kind/bug org:kubernetes -project:kubernetes/59",
+	}},
+	{49, []string{
+		"is:open label:sig/node is:pr org:kubernetes -project:kubernetes/49",
+	}},
+}
+
+// addToTriage adds the items of every triage rule to the Triage column of
+// its project. A rule that fails is logged and the others still run; the
+// failures are returned together at the end.
+func addToTriage(ctx context.Context, client *github.Client) error {
+	var errs []error
+	for _, rule := range triageRules {
+		columnID, err := getColumnID(ctx, client, "kubernetes", rule.Project, "Triage")
+		if err != nil {
+			logging.From(ctx).Error("skipping the rules of a project without its Triage column", "project", rule.Project, "err", err)
+			errs = append(errs, fmt.Errorf("project %d: %w", rule.Project, err))
+			continue
+		}
+		for _, q := range rule.Queries {
+			if err := addIssuesToColumn(ctx, client, q, columnID); err != nil {
+				errs = append(errs, fmt.Errorf("rule %q: %w", q, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func nodePRsDo(w http.ResponseWriter, r *http.Request) {
+
+	logging.From(r.Context()).Info("processing node PRs")
+
+	ctx, client := newGitHubClient(r)
+
+	if err := addToTriage(ctx, client); err != nil {
+		fmt.Fprintf(w, "something went wrong: %q", err)
+		return
+	}
+
+	fmt.Fprintf(w, "Hello, World!\n")
+}
+
+func getColumnID(ctx context.Context, client *github.Client, org string, projectNumber int, columnsName string) (int64, error) {
+	projects, _, err := client.Organizations.ListProjects(ctx, org, &github.ProjectListOptions{State: "open", ListOptions: github.ListOptions{Page: 1, PerPage: 100}})
+
+	l := logging.From(ctx)
+
+	if err != nil {
+		l.Error("Organizations.ListProjects returned error", "err", err)
+		return -1, fmt.Errorf("Organizations.ListProjects returned error: %w", err)
+	}
+
+	var targetProject *github.Project
+
+	for _, p := range projects {
+		l.Debug("project", "id", *p.ID, "name", *p.Name, "url", *p.HTMLURL, "number", *p.Number)
+		if *p.Number == projectNumber {
+			targetProject = p
+			break
+		}
+	}
+
+	if targetProject == nil {
+		l.Error("project not found", "org", org, "number", projectNumber)
+		return -1, errors.New("Project not found")
+	}
+
+	columns, _, err := client.Projects.ListProjectColumns(ctx, *targetProject.ID, &github.ListOptions{Page: 1, PerPage: 100})
+
+	if err != nil {
+		l.Error("Projects.ListProjectColumns returned error", "err", err)
+		return -1, fmt.Errorf("Projects.ListProjectColumns returned error: %w", err)
+	}
+
+	l.Info("found project", "url", *targetProject.URL)
+
+	var targetColumn *github.ProjectColumn
+	for _, c := range columns {
+		//fmt.Printf("Column: %d %s\n", *c.ID, *c.Name)
+
+		if *c.Name == columnsName {
+			targetColumn = c
+		}
+	}
+
+	if targetColumn == nil {
+		l.Error("column not found", "column", columnsName)
+		return -1, errors.New("Column not found")
+	}
+
+	l.Info("found column", "id", *targetColumn.ID, "name", *targetColumn.Name)
+
+	return *targetColumn.ID, nil
+
+}
+
+func addIssuesToColumn(ctx context.Context, client *github.Client, query string, columnID int64) error {
+	opts := &github.SearchOptions{
+		Sort:        "forks",
+		Order:       "desc",
+		ListOptions: github.ListOptions{Page: 1, PerPage: 100},
+	}
+
+	l := logging.From(ctx).With("rule", query)
+
+	result, _, err := client.Search.Issues(ctx, query, opts)
+	if err != nil {
+		l.Error("Search.Issues returned error", "err", err)
+		return err
+	}
+
+	for _, issue := range result.Issues {
+		l.Debug("issue", "id", *issue.ID, "node_id", *issue.NodeID, "title", *issue.Title, "number", *issue.Number)
+
+		input := &github.ProjectCardOptions{
+			ContentID:   *issue.ID,
+			ContentType: "Issue",
+		}
+
+		card, _, err := client.Projects.CreateProjectCard(ctx, columnID, input)
+
+		if err != nil {
+			l.Error("Projects.CreateProjectCard returned error", "err", err, "issue", *issue.Number)
+			return err
+		}
+
+		metrics.Add("triage_cards_created_total", 1, "rule", query)
+
+		l.Info("created card", "url", *card.URL, "issue", *issue.Number)
+	}
+
+	return nil
+
+}
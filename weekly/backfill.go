@@ -0,0 +1,51 @@
+package weekly
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/sheet"
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+)
+
+// Backfill stores the Weekly rows written to the sheet before the snapshot
+// store was kept as snapshots, with the queries of the window of each row.
+// Rows newer than the first stored snapshot are left alone. With dryRun
+// the snapshots are only counted.
+func Backfill(dryRun bool) error {
+	rows, err := sheet.Read("Weekly", 24)
+	if err != nil {
+		return err
+	}
+
+	var older []snapshots.Snapshot
+	for _, r := range rows {
+		if len(r) < 2 {
+			continue
+		}
+		lastMeeting, err := time.Parse("2006-01-02T15:04:05-0700", fmt.Sprint(r[0]))
+		if err != nil {
+			continue
+		}
+		dateNow, err := time.Parse("2006-01-02T15:04:05-0700", fmt.Sprint(r[1]))
+		if err != nil {
+			continue
+		}
+
+		s := snapshots.FromRow(r, 2, weeklyColumns(lastMeeting, dateNow))
+		s.Time = dateNow
+		older = append(older, *s)
+	}
+
+	if dryRun {
+		logging.Logger.Info("would backfill snapshots", "sheet", "Weekly", "rows", len(older))
+		return nil
+	}
+	n, err := snapshots.Prepend("weekly", older)
+	if err != nil {
+		return fmt.Errorf("failed to backfill weekly: %v", err)
+	}
+	logging.Logger.Info("backfilled snapshots", "sheet", "Weekly", "set", "weekly", "added", n)
+	return nil
+}
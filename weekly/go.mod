@@ -1,10 +0,0 @@
-module main
-
-require google.golang.org/api v0.29.0
-
-require (
-	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e
-	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
-)
-
-go 1.12
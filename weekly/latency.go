@@ -0,0 +1,256 @@
+package weekly
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+)
+
+// Latency metrics of sig/node PRs and issues, computed from issue timelines:
+// https://docs.github.com/en/rest/issues/timeline
+//
+// Response, review and lgtm latencies are measured for the items created in
+// the weekly window; merge latency for the PRs merged in it. Items without
+// the event yet are censored: they count with their age at the end of the
+// window, so slow weeks are not hidden, and waiting tells how many of the n
+// items that is. Timelines need ACCESS_TOKEN; without it latency is skipped.
+
+const latencyRepo = "kubernetes/kubernetes"
+
+type ghUser struct {
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+type searchItem struct {
+	Number      int       `json:"number"`
+	Title       string    `json:"title"`
+	HTMLURL     string    `json:"html_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	User        ghUser    `json:"user"`
+	PullRequest *struct {
+		MergedAt *time.Time `json:"merged_at"`
+	} `json:"pull_request"`
+}
+
+type timelineEvent struct {
+	Event       string     `json:"event"`
+	CreatedAt   *time.Time `json:"created_at"`
+	SubmittedAt *time.Time `json:"submitted_at"`
+	Actor       *ghUser    `json:"actor"`
+	User        *ghUser    `json:"user"`
+	Label       *struct {
+		Name string `json:"name"`
+	} `json:"label"`
+}
+
+// ignoredUsers are accounts whose activity is not a human response. Override
+// with a comma separated LATENCY_IGNORE_USERS.
+func ignoredUsers() map[string]bool {
+	list := os.Getenv("LATENCY_IGNORE_USERS")
+	if list == "" {
+		list = "k8s-ci-robot,k8s-triage-robot,k8s-infra-ci-robot"
+	}
+	users := map[string]bool{}
+	for _, u := range strings.Split(list, ",") {
+		users[strings.TrimSpace(u)] = true
+	}
+	return users
+}
+
+func isBot(u *ghUser, ignored map[string]bool) bool {
+	return u == nil || u.Type == "Bot" || strings.HasSuffix(u.Login, "[bot]") || ignored[u.Login]
+}
+
+func getTimeline(number int) ([]timelineEvent, error) {
+	var events []timelineEvent
+	next := fmt.Sprintf("https://api.github.com/repos/%s/issues/%d/timeline?per_page=100", latencyRepo, number)
+	for next != "" {
+		var page []timelineEvent
+		current := next
+		err := ghclient.WithRetry(fmt.Sprintf("timeline of #%d", number), func() (err error) {
+			next, err = ghclient.Get(current, &page)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page...)
+	}
+	return events, nil
+}
+
+// itemLatency holds the time from creation to each event; nil when the event
+// did not happen (yet). Age is the time from creation to the end of the
+// window, the censored latency of the events that did not happen.
+type itemLatency struct {
+	Age           time.Duration
+	FirstResponse *time.Duration
+	FirstReview   *time.Duration
+	LGTM          *time.Duration
+	Merge         *time.Duration
+}
+
+func since(created time.Time, t *time.Time, current *time.Duration) *time.Duration {
+	if t == nil {
+		return current
+	}
+	d := t.Sub(created)
+	if current == nil || d < *current {
+		return &d
+	}
+	return current
+}
+
+func getItemLatency(item searchItem, events []timelineEvent, ignored map[string]bool) itemLatency {
+	var l itemLatency
+	author := item.User.Login
+
+	for _, e := range events {
+		switch e.Event {
+		case "commented":
+			if !isBot(e.Actor, ignored) && e.Actor.Login != author {
+				l.FirstResponse = since(item.CreatedAt, e.CreatedAt, l.FirstResponse)
+			}
+		case "reviewed":
+			if !isBot(e.User, ignored) && e.User.Login != author {
+				l.FirstResponse = since(item.CreatedAt, e.SubmittedAt, l.FirstResponse)
+				l.FirstReview = since(item.CreatedAt, e.SubmittedAt, l.FirstReview)
+			}
+		case "labeled":
+			if e.Label != nil && e.Label.Name == "lgtm" {
+				l.LGTM = since(item.CreatedAt, e.CreatedAt, l.LGTM)
+			}
+		case "merged":
+			l.Merge = since(item.CreatedAt, e.CreatedAt, l.Merge)
+		}
+	}
+
+	if l.Merge == nil && item.PullRequest != nil {
+		l.Merge = since(item.CreatedAt, item.PullRequest.MergedAt, nil)
+	}
+	return l
+}
+
+// getLatencies fetches the timelines of the items on a bounded pool of
+// workers.
+func getLatencies(items []searchItem, end time.Time) ([]itemLatency, error) {
+	ignored := ignoredUsers()
+	latencies := make([]itemLatency, len(items))
+	errs := make([]error, len(items))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < ghclient.Parallelism(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				events, err := getTimeline(items[i].Number)
+				if err != nil {
+					errs[i] = fmt.Errorf("timeline of #%d: %v", items[i].Number, err)
+					continue
+				}
+				latencies[i] = getItemLatency(items[i], events, ignored)
+				latencies[i].Age = end.Sub(items[i].CreatedAt)
+			}
+		}()
+	}
+
+	for i := range items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return latencies, nil
+}
+
+// percentile returns the nearest-rank percentile of sorted durations in hours.
+func percentile(sorted []time.Duration, p float64) float64 {
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return math.Round(sorted[rank].Hours()*10) / 10
+}
+
+// appendPercentiles appends p50, p90 (in hours), n and waiting of the
+// selected latency to the row. Items still waiting for the event count with
+// their age, which their latency will only exceed, so the percentiles are
+// lower bounds that no longer leave out the slowest items. Percentiles are
+// left empty without items.
+func appendPercentiles(row []interface{}, latencies []itemLatency, pick func(itemLatency) *time.Duration) []interface{} {
+	var values []time.Duration
+	waiting := 0
+	for _, l := range latencies {
+		if d := pick(l); d != nil {
+			values = append(values, *d)
+		} else {
+			values = append(values, l.Age)
+			waiting++
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	if len(values) == 0 {
+		return append(row, "", "", 0, 0)
+	}
+	return append(row, percentile(values, 50), percentile(values, 90), len(values), waiting)
+}
+
+// getLatency returns the latency row for the window: from, time, then p50,
+// p90, n and waiting of PR first response, PR first review, PR lgtm, PR merge and
+// issue first response.
+func getLatency(lastMeeting, dateNow time.Time) ([]interface{}, error) {
+	if ghclient.Token() == "" {
+		return nil, errors.New("ACCESS_TOKEN is needed to read timelines")
+	}
+
+	var dateNowStr = dateNow.Format("2006-01-02T15:04:05-0700")
+	var lastMeetingDateStr = lastMeeting.Format("2006-01-02T15:04:05-0700")
+	var dateRange = lastMeetingDateStr + ".." + dateNowStr
+
+	baseQuery := "repo:" + latencyRepo + " label:sig/node "
+
+	queries := []string{
+		baseQuery + "type:pr created:" + dateRange,
+		baseQuery + "type:pr merged:" + dateRange,
+		baseQuery + "type:issue created:" + dateRange,
+	}
+
+	var latencies [][]itemLatency
+	for _, query := range queries {
+		items, _, err := ghclient.Search[searchItem](query)
+		if err != nil {
+			return nil, err
+		}
+		l, err := getLatencies(items, dateNow)
+		if err != nil {
+			return nil, err
+		}
+		latencies = append(latencies, l)
+	}
+	created, merged, issues := latencies[0], latencies[1], latencies[2]
+
+	result := []interface{}{lastMeetingDateStr, dateNowStr}
+	result = appendPercentiles(result, created, func(l itemLatency) *time.Duration { return l.FirstResponse })
+	result = appendPercentiles(result, created, func(l itemLatency) *time.Duration { return l.FirstReview })
+	result = appendPercentiles(result, created, func(l itemLatency) *time.Duration { return l.LGTM })
+	result = appendPercentiles(result, merged, func(l itemLatency) *time.Duration { return l.Merge })
+	result = appendPercentiles(result, issues, func(l itemLatency) *time.Duration { return l.FirstResponse })
+
+	return result, nil
+}
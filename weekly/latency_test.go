@@ -0,0 +1,63 @@
+package weekly
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGetItemLatency(t *testing.T) {
+	created := time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC)
+	at := func(h int) *time.Time { t := created.Add(time.Duration(h) * time.Hour); return &t }
+	user := func(login, kind string) *ghUser { return &ghUser{Login: login, Type: kind} }
+	lgtm := &struct {
+		Name string `json:"name"`
+	}{"lgtm"}
+
+	item := searchItem{CreatedAt: created, User: ghUser{Login: "author"}}
+	events := []timelineEvent{
+		{Event: "commented", CreatedAt: at(1), Actor: user("author", "User")},
+		{Event: "commented", CreatedAt: at(2), Actor: user("k8s-ci-robot", "User")},
+		{Event: "commented", CreatedAt: at(3), Actor: user("dependabot[bot]", "Bot")},
+		{Event: "reviewed", SubmittedAt: at(6), User: user("reviewer", "User")},
+		{Event: "commented", CreatedAt: at(5), Actor: user("member", "User")},
+		{Event: "labeled", CreatedAt: at(8), Label: lgtm},
+		{Event: "merged", CreatedAt: at(9)},
+	}
+	l := getItemLatency(item, events, ignoredUsers())
+	for name, d := range map[string]*time.Duration{"response": l.FirstResponse, "review": l.FirstReview, "lgtm": l.LGTM, "merge": l.Merge} {
+		want := map[string]time.Duration{"response": 5 * time.Hour, "review": 6 * time.Hour, "lgtm": 8 * time.Hour, "merge": 9 * time.Hour}[name]
+		if d == nil || *d != want {
+			t.Errorf("%s latency %v, want %v", name, d, want)
+		}
+	}
+
+	if l := getItemLatency(item, events[:3], ignoredUsers()); l.FirstResponse != nil || l.Merge != nil {
+		t.Errorf("latency without a response %+v, want none", l)
+	}
+}
+
+func TestAppendPercentiles(t *testing.T) {
+	hours := func(h int) *time.Duration { d := time.Duration(h) * time.Hour; return &d }
+	responded := func(h int) itemLatency { return itemLatency{Age: 100 * time.Hour, FirstResponse: hours(h)} }
+	waiting := func(age int) itemLatency { return itemLatency{Age: time.Duration(age) * time.Hour} }
+	pick := func(l itemLatency) *time.Duration { return l.FirstResponse }
+
+	for _, tc := range []struct {
+		name      string
+		latencies []itemLatency
+		want      string
+	}{
+		{"none", nil, "[  0 0]"},
+		{"all responded", []itemLatency{responded(1), responded(2), responded(3), responded(4)}, "[2 4 4 0]"},
+		// the two waiting items count with their 50 and 60 hours so far
+		{"waiting", []itemLatency{responded(1), responded(2), waiting(50), waiting(60)}, "[2 60 4 2]"},
+		{"all waiting", []itemLatency{waiting(10), waiting(30)}, "[10 30 2 2]"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fmt.Sprint(appendPercentiles(nil, tc.latencies, pick)); got != tc.want {
+				t.Errorf("appendPercentiles = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package weekly
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+	"github.com/google/go-github/v40/github"
+)
+
+// The weekly report can also be published on GitHub, for the contributors
+// who cannot see the spreadsheet: as a comment on a tracking issue or as a
+// discussion. A marker with the meeting date in the body finds the post of
+// the week again, so reruns update it in place instead of posting again.
+
+// report is the markdown weekly report: the counts with their change since
+// the same time last week, the PRs that entered and left every column since
+// then when both weeks listed them, and the PRs behind every windowed column.
+func report(lastMeeting, dateNow time.Time, columns []snapshots.Column, s snapshots.Snapshot, previous *snapshots.Snapshot, items map[string][]searchItem) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n", publishMarker(lastMeeting))
+	fmt.Fprintf(&b, "## sig/node PRs %s to %s\n\n", lastMeeting.Format("2006-01-02 15:04"), dateNow.Format("2006-01-02 15:04 MST"))
+	fmt.Fprintf(&b, "| | PRs | vs same time last week |\n|---|---|---|\n")
+	for _, v := range columns {
+		link := ghclient.SearchURL(v.Query)
+
+		count, ok := s.Count(v.Name)
+		if !ok {
+			fmt.Fprintf(&b, "| %s | [n/a](%s) | |\n", v.Name, link)
+			continue
+		}
+		change := ""
+		if prev, ok := previous.Count(v.Name); ok {
+			change = fmt.Sprintf("%+d", count-prev)
+		}
+		fmt.Fprintf(&b, "| %s | [%d](%s) | %s |\n", v.Name, count, link, change)
+	}
+
+	for _, v := range columns {
+		entered, left, ok := s.Changes(previous, v.Name)
+		if !ok || len(entered)+len(left) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s since the same time last week\n\n", v.Name)
+		if len(entered) > 0 {
+			fmt.Fprintf(&b, "- entered: %s\n", itemLinks(entered))
+		}
+		if len(left) > 0 {
+			fmt.Fprintf(&b, "- left: %s\n", itemLinks(left))
+		}
+	}
+
+	for _, v := range columns {
+		list, ok := items[v.Name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s (%d)\n\n", v.Name, len(list))
+		for _, i := range list {
+			fmt.Fprintf(&b, "- [#%d](%s) %s\n", i.Number, i.HTMLURL, i.Title)
+		}
+	}
+	return b.String()
+}
+
+// itemLinks lists owner/repo#number items as markdown links.
+func itemLinks(refs []string) string {
+	var links []string
+	for _, ref := range refs {
+		links = append(links, fmt.Sprintf("[%s](%s)", ref, ghclient.ItemURL(ref)))
+	}
+	return strings.Join(links, ", ")
+}
+
+// digest is the weekly report for the webhooks, in Slack mrkdwn.
+func digest(lastMeeting time.Time, columns []snapshots.Column, s snapshots.Snapshot, previous *snapshots.Snapshot) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "*sig/node PRs since the meeting of %s*, change vs the same time last week\n", lastMeeting.Format("2006-01-02"))
+	for _, v := range columns {
+		link := ghclient.SearchURL(v.Query)
+
+		count, ok := s.Count(v.Name)
+		if !ok {
+			fmt.Fprintf(&b, "• %s: <%s|n/a>\n", v.Name, link)
+			continue
+		}
+		fmt.Fprintf(&b, "• %s: <%s|%d>", v.Name, link, count)
+		if prev, ok := previous.Count(v.Name); ok {
+			fmt.Fprintf(&b, " (%+d)", count-prev)
+		}
+		if entered, left, ok := s.Changes(previous, v.Name); ok {
+			fmt.Fprintf(&b, ", %d in, %d out", len(entered), len(left))
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+	return b.String()
+}
+
+// reportItems lists the PRs of every column but the open total.
+func reportItems(columns []snapshots.Column) (map[string][]searchItem, error) {
+	items := map[string][]searchItem{}
+	for _, v := range columns {
+		if v.Name == "total" {
+			continue
+		}
+		list, _, err := ghclient.Search[searchItem](v.Query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s PRs: %v", v.Name, err)
+		}
+		items[v.Name] = list
+	}
+	return items, nil
+}
+
+func publishMarker(lastMeeting time.Time) string {
+	return fmt.Sprintf("<!-- sig-node-weekly %s -->", lastMeeting.Format("2006-01-02"))
+}
+
+// publishComment adds the report as a comment on the issue, or edits the
+// comment of the same week.
+func publishComment(ctx context.Context, client *github.Client, repo string, number int, marker, body string) (string, error) {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok {
+		return "", fmt.Errorf("repo %q is not owner/name", repo)
+	}
+
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := client.Issues.ListComments(ctx, owner, name, number, opts)
+		if err != nil {
+			return "", fmt.Errorf("Issues.ListComments returned error: %w", err)
+		}
+		for _, c := range comments {
+			if strings.Contains(c.GetBody(), marker) {
+				c, _, err := client.Issues.EditComment(ctx, owner, name, c.GetID(), &github.IssueComment{Body: &body})
+				if err != nil {
+					return "", fmt.Errorf("Issues.EditComment returned error: %w", err)
+				}
+				return c.GetHTMLURL(), nil
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	c, _, err := client.Issues.CreateComment(ctx, owner, name, number, &github.IssueComment{Body: &body})
+	if err != nil {
+		return "", fmt.Errorf("Issues.CreateComment returned error: %w", err)
+	}
+	return c.GetHTMLURL(), nil
+}
+
+// graphql runs a GraphQL query with the client; discussions are not in the
+// REST API.
+func graphql(ctx context.Context, client *github.Client, query string, variables map[string]interface{}, v interface{}) error {
+	req, err := client.NewRequest(http.MethodPost, "graphql", map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Data   interface{} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	resp.Data = v
+	if _, err := client.Do(ctx, req, &resp); err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		return errors.New(resp.Errors[0].Message)
+	}
+	return nil
+}
+
+// publishDiscussion starts a discussion with the report in the category,
+// or updates the discussion of the same week.
+func publishDiscussion(ctx context.Context, client *github.Client, repo, category, title, marker, body string) (string, error) {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok {
+		return "", fmt.Errorf("repo %q is not owner/name", repo)
+	}
+
+	var found struct {
+		Repository struct {
+			ID         string `json:"id"`
+			Categories struct {
+				Nodes []struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"nodes"`
+			} `json:"discussionCategories"`
+			Discussions struct {
+				Nodes []struct {
+					ID   string `json:"id"`
+					URL  string `json:"url"`
+					Body string `json:"body"`
+				} `json:"nodes"`
+			} `json:"discussions"`
+		} `json:"repository"`
+	}
+	err := graphql(ctx, client, `query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) {
+    id
+    discussionCategories(first: 50) { nodes { id name } }
+    discussions(first: 50, orderBy: {field: CREATED_AT, direction: DESC}) { nodes { id url body } }
+  }
+}`, map[string]interface{}{"owner": owner, "name": name}, &found)
+	if err != nil {
+		return "", fmt.Errorf("failed to find discussions of %s: %v", repo, err)
+	}
+
+	for _, d := range found.Repository.Discussions.Nodes {
+		if !strings.Contains(d.Body, marker) {
+			continue
+		}
+		err := graphql(ctx, client, `mutation($id: ID!, $body: String!) {
+  updateDiscussion(input: {discussionId: $id, body: $body}) { discussion { id } }
+}`, map[string]interface{}{"id": d.ID, "body": body}, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to update discussion: %v", err)
+		}
+		return d.URL, nil
+	}
+
+	categoryID := ""
+	for _, c := range found.Repository.Categories.Nodes {
+		if c.Name == category {
+			categoryID = c.ID
+		}
+	}
+	if categoryID == "" {
+		return "", fmt.Errorf("discussion category %q not found in %s", category, repo)
+	}
+
+	var created struct {
+		CreateDiscussion struct {
+			Discussion struct {
+				URL string `json:"url"`
+			} `json:"discussion"`
+		} `json:"createDiscussion"`
+	}
+	err = graphql(ctx, client, `mutation($repo: ID!, $category: ID!, $title: String!, $body: String!) {
+  createDiscussion(input: {repositoryId: $repo, categoryId: $category, title: $title, body: $body}) { discussion { url } }
+}`, map[string]interface{}{"repo": found.Repository.ID, "category": categoryID, "title": title, "body": body}, &created)
+	if err != nil {
+		return "", fmt.Errorf("failed to create discussion: %v", err)
+	}
+	return created.CreateDiscussion.Discussion.URL, nil
+}
+
+type publishOptions struct {
+	Mode     string
+	Repo     string
+	Issue    int
+	Category string
+	// Digest only posts the digest of the latest snapshot to the webhooks.
+	Digest bool
+}
+
+// check fails for options the report cannot be published with, so the
+// run fails before it counts and writes anything.
+func (opts publishOptions) check() error {
+	switch opts.Mode {
+	case "":
+		return nil
+	case "comment":
+		if opts.Issue == 0 {
+			return errors.New("-issue is needed to publish a comment")
+		}
+	case "discussion":
+	default:
+		return fmt.Errorf("unknown -publish %q, want comment or discussion", opts.Mode)
+	}
+	if ghclient.Token() == "" {
+		return errors.New("ACCESS_TOKEN is needed to publish")
+	}
+	return nil
+}
+
+// publish posts the report the way the options ask for; the options are
+// checked already.
+func publish(opts publishOptions, lastMeeting, dateNow time.Time, columns []snapshots.Column, s snapshots.Snapshot, previous *snapshots.Snapshot) error {
+	token := ghclient.Token()
+
+	items, err := reportItems(columns)
+	if err != nil {
+		return err
+	}
+	body := report(lastMeeting, dateNow, columns, s, previous, items)
+	marker := publishMarker(lastMeeting)
+
+	ctx := context.Background()
+	client := ghclient.New(ctx, token)
+
+	var link string
+	switch opts.Mode {
+	case "comment":
+		link, err = publishComment(ctx, client, opts.Repo, opts.Issue, marker, body)
+	case "discussion":
+		title := fmt.Sprintf("sig/node weekly PRs %s", lastMeeting.Format("2006-01-02"))
+		link, err = publishDiscussion(ctx, client, opts.Repo, opts.Category, title, marker, body)
+	}
+	if err != nil {
+		return err
+	}
+
+	logging.Logger.Info("published weekly report", "mode", opts.Mode, "url", link)
+	return nil
+}
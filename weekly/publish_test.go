@@ -0,0 +1,92 @@
+package weekly
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
+)
+
+func column(name string, count int, items ...string) snapshots.Column {
+	c := snapshots.Column{Name: name, Query: name, Count: &count}
+	if items != nil {
+		c.Items = items
+	}
+	return c
+}
+
+var (
+	meeting = time.Date(2026, 10, 13, 17, 0, 0, 0, time.UTC)
+	now     = time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	columns = []snapshots.Column{{Name: "total", Query: "total"}, {Name: "created", Query: "created"}, {Name: "merged", Query: "merged"}}
+)
+
+func TestReport(t *testing.T) {
+	s := snapshots.Snapshot{Time: now, Columns: []snapshots.Column{
+		column("total", 120),
+		column("created", 12, "o/r#1", "o/r#2"),
+		{Name: "merged", Query: "merged"},
+	}}
+	previous := &snapshots.Snapshot{Columns: []snapshots.Column{
+		column("total", 125),
+		column("created", 10, "o/r#2", "o/r#3"),
+		column("merged", 4),
+	}}
+	items := map[string][]searchItem{"created": {{Number: 1, Title: "fix kubelet", HTMLURL: "https://github.com/o/r/pull/1"}}}
+
+	got := report(meeting, now, columns, s, previous, items)
+	for _, want := range []string{
+		publishMarker(meeting),
+		"## sig/node PRs 2026-10-13 17:00 to 2026-10-19 10:00 UTC",
+		"| | PRs | vs same time last week |",
+		"| total | [120](https://github.com/issues?q=total) | -5 |",
+		"| created | [12](https://github.com/issues?q=created) | +2 |",
+		"| merged | [n/a](https://github.com/issues?q=merged) | |",
+		"### created since the same time last week",
+		"- entered: [o/r#1](https://github.com/o/r/issues/1)",
+		"- left: [o/r#3](https://github.com/o/r/issues/3)",
+		"### created (1)\n\n- [#1](https://github.com/o/r/pull/1) fix kubelet",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report does not have %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "### total since") {
+		t.Errorf("report lists changes of a column without items:\n%s", got)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	s := snapshots.Snapshot{Time: now, Columns: []snapshots.Column{
+		column("total", 120),
+		column("created", 12, "o/r#1", "o/r#2"),
+		{Name: "merged", Query: "merged"},
+	}}
+
+	for _, tc := range []struct {
+		name     string
+		previous *snapshots.Snapshot
+		want     []string
+	}{
+		{"with last week", &snapshots.Snapshot{Columns: []snapshots.Column{column("total", 125), column("created", 10, "o/r#2", "o/r#3")}}, []string{
+			"*sig/node PRs since the meeting of 2026-10-13*, change vs the same time last week\n",
+			"• total: <https://github.com/issues?q=total|120> (-5)\n",
+			"• created: <https://github.com/issues?q=created|12> (+2), 1 in, 1 out\n",
+			"• merged: <https://github.com/issues?q=merged|n/a>\n",
+		}},
+		{"without last week", nil, []string{
+			"• total: <https://github.com/issues?q=total|120>\n",
+			"• created: <https://github.com/issues?q=created|12>\n",
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := digest(meeting, columns, s, tc.previous)
+			for _, want := range tc.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("digest does not have %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
@@ -1,62 +1,28 @@
-package main
+// Package weekly counts the sig/node PRs of the week since the last
+// sig/node meeting, with their review latencies, for the weekly report.
+package weekly
 
 import (
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"net/http"
-	"net/url"
-	"os"
 	"time"
 
-	"golang.org/x/net/context"
-	"google.golang.org/api/option"
-	sheets "google.golang.org/api/sheets/v4"
+	"github.com/SergeyKanzhelev/github-queries/internal/alerts"
+	"github.com/SergeyKanzhelev/github-queries/internal/ghclient"
+	"github.com/SergeyKanzhelev/github-queries/internal/logging"
+	"github.com/SergeyKanzhelev/github-queries/internal/metrics"
+	"github.com/SergeyKanzhelev/github-queries/internal/notify"
+	"github.com/SergeyKanzhelev/github-queries/internal/sheet"
+	"github.com/SergeyKanzhelev/github-queries/internal/snapshots"
 )
 
-type prs struct {
-	TotalCount int `json:"total_count"`
-}
-
-type column struct {
-	ColumnName string
-	Labels     string
-}
-
-func getPRsCount(query string) (int, error) {
-	q := url.Values{}
-	q.Add("q", query)
-	q.Add("per_page", "1")
-
-	resp, err := http.Get("https://api.github.com/search/issues?" + q.Encode())
-
-	if err != nil {
-		return -1, fmt.Errorf("failed to get PRs: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return -1, fmt.Errorf("status code is not 200: %v", resp.StatusCode)
-	}
-
-	var result = prs{}
-
-	err = json.NewDecoder(resp.Body).Decode(&result)
-	if err != nil {
-		fmt.Errorf("Failed to parse JSON PRs: %v", err)
-		return -1, err
-	}
-
-	return result.TotalCount, nil
-}
-
-func getPRs() ([]interface{}, error) {
-	// see documentation
-	// https://developer.github.com/v3/search/#search-issues-and-pull-requests
-	// https://docs.github.com/en/github/searching-for-information-on-github/searching-issues-and-pull-requests
+// meetingWindow returns the start of the last sig/node meeting (Tuesday
+// 17:00 UTC) and the current time; weekly numbers cover this window.
+func meetingWindow() (lastMeeting, dateNow time.Time) {
+	dateNow = time.Now().UTC()
 
-	var dateNow = time.Now().UTC()
-
-	var lastMeeting = dateNow
+	lastMeeting = dateNow
 
 	if lastMeeting.Weekday() == time.Tuesday && lastMeeting.Hour() < 17 {
 		lastMeeting = lastMeeting.AddDate(0, 0, -7).UTC()
@@ -76,89 +42,197 @@ func getPRs() ([]interface{}, error) {
 	//lastMeeting = lastMeeting.AddDate(0, 0, -7).UTC()
 	//lastMeeting = lastMeeting.AddDate(0, 0, -7).UTC()
 
+	return lastMeeting, dateNow
+}
+
+// weeklyColumns are the PR searches of the window from the last meeting
+// to now.
+func weeklyColumns(lastMeeting, dateNow time.Time) []snapshots.Column {
+	// see documentation
+	// https://developer.github.com/v3/search/#search-issues-and-pull-requests
+	// https://docs.github.com/en/github/searching-for-information-on-github/searching-issues-and-pull-requests
+
 	baseQuery := "repo:kubernetes/kubernetes type:pr label:sig/node "
 
 	var dateNowStr = dateNow.Format("2006-01-02T15:04:05-0700")
 	var lastMeetingDateStr = lastMeeting.Format("2006-01-02T15:04:05-0700")
 	var dateRange = lastMeetingDateStr + ".." + dateNowStr
 
-	columns := []column{
-		column{"total", baseQuery + "is:open "},
-		column{"created", baseQuery + " created:" + dateRange},
-		column{"updated", baseQuery + "updated:" + dateRange + " created:<" + lastMeetingDateStr},
-		column{"closed", baseQuery + " is:unmerged closed:" + dateRange},
-		column{"merged", baseQuery + " merged:" + dateRange},
+	// shrug: " -label:¯\\_(ツ)_/¯ "
+
+	return []snapshots.Column{
+		{Name: "total", Query: baseQuery + "is:open "},
+		{Name: "created", Query: baseQuery + " created:" + dateRange},
+		{Name: "updated", Query: baseQuery + "updated:" + dateRange + " created:<" + lastMeetingDateStr},
+		{Name: "closed", Query: baseQuery + " is:unmerged closed:" + dateRange},
+		{Name: "merged", Query: baseQuery + " merged:" + dateRange},
 	}
+}
 
-	// shrug: " -label:¯\\_(ツ)_/¯ "
+// Columns are the columns of the Weekly row counted now, for the window
+// since the last meeting.
+func Columns() []snapshots.Column {
+	return weeklyColumns(meetingWindow())
+}
+
+// getPRs counts the columns into a sheet row, followed by the change of
+// every column since the same time last week, and returns the counts as a
+// snapshot. With links the counts link to their searches.
+func getPRs(lastMeeting, dateNow time.Time, columns []snapshots.Column, previous *snapshots.Snapshot, links bool) ([]interface{}, snapshots.Snapshot, error) {
+	var dateNowStr = dateNow.Format("2006-01-02T15:04:05-0700")
+	var lastMeetingDateStr = lastMeeting.Format("2006-01-02T15:04:05-0700")
+
+	s := snapshots.Snapshot{Time: dateNow}
 
-	header := "from"
 	result := []interface{}{}
 	result = append(result, lastMeetingDateStr)
-
-	header += "time"
 	result = append(result, dateNowStr)
-	for _, v := range columns {
-		count, err := getPRsCount(v.Labels)
-		if err != nil {
-			return nil, fmt.Errorf("error for query %s: %v", v.Labels, err)
+	counts, items, errs := ghclient.CountItems(snapshots.Queries(columns), snapshots.Listing())
+	if err := ghclient.AllFailed(errs); err != nil {
+		return nil, s, err
+	}
+	for i, v := range columns {
+		sc := snapshots.Column{Name: v.Name, Query: v.Query}
+		s.Columns = append(s.Columns, sc)
+		if errs[i] != nil {
+			logging.Logger.Warn("leaving column empty", "column", v.Name, "err", errs[i])
+			result = append(result, "")
+			continue
 		}
-		//=HYPERLINK("https://github.com/kubernetes/kubernetes/pulls?q=repo%3Akubernetes%2Fkubernetes+type%3Apr+label%3Asig%2Fnode++created%3A%3E%3D2020-08-04T17%3A00%3A00%2B0000", "created")
-
-		q := url.Values{}
-		q.Add("q", v.Labels)
-		urlStr := fmt.Sprintf("https://github.com/kubernetes/kubernetes/pulls?%s", q.Encode())
-
-		var hyperlinkStr = fmt.Sprintf("=HYPERLINK(\"%s\", \"%d\")", urlStr, count)
-		result = append(result, hyperlinkStr)
-		header += fmt.Sprintf(", \"%s\"", v.ColumnName)
+		count := counts[i]
+		s.Columns[i].Count = &count
+		s.Columns[i].Items = items[i]
+		if links {
+			result = append(result, sheet.Link(ghclient.SearchURL(v.Query), count))
+		} else {
+			result = append(result, count)
+		}
+		metrics.Set("dashboard_column_count", float64(count), "dashboard", "Weekly", "column", v.Name)
+	}
 
+	for i, v := range columns {
+		prev, ok := previous.Count(v.Name)
+		if errs[i] != nil || !ok {
+			result = append(result, "")
+			continue
+		}
+		result = append(result, counts[i]-prev)
 	}
 
-	return result, nil
+	return result, s, nil
 }
 
-func writeToSheet(values []interface{}) error {
-	// Service account based oauth2 two legged integration
-	ctx := context.Background()
-	srv, err := sheets.NewService(ctx, option.WithCredentialsFile("credentials.json"), option.WithScopes(sheets.SpreadsheetsScope))
+// previousSnapshot returns the counts at the same time last week, as far
+// into the previous week's window as the counts at dateNow are into this
+// one: the last snapshot in the store taken a week before dateNow or, without
+// one, the last row of the Weekly sheet written by then.
+func previousSnapshot(columns []snapshots.Column, dateNow time.Time) *snapshots.Snapshot {
+	weekAgo := dateNow.Add(-7 * 24 * time.Hour)
+	s, err := snapshots.Before("weekly", weekAgo)
 	if err != nil {
-		return fmt.Errorf("unable to retrieve Sheets client: %v", err)
+		logging.Logger.Warn("failed to load previous snapshot", "err", err)
+	}
+	if s != nil {
+		return s
 	}
 
-	// https://docs.google.com/spreadsheets/d/1VW5_Eq8MzswfDi9xEvfYyP8edF_Ny7MBANIsJXT3VGw/edit
-	spreadsheetId := "1VW5_Eq8MzswfDi9xEvfYyP8edF_Ny7MBANIsJXT3VGw"
-	readRange := "Weekly!A24:G"
-	resp, err := srv.Spreadsheets.Values.Get(spreadsheetId, readRange).Do()
+	// three weeks of hourly runs reach back past a week ago
+	rows, err := sheet.Tail("Weekly", 24, 500)
 	if err != nil {
-		return fmt.Errorf("unable to retrieve data from sheet: %v", err)
+		logging.Logger.Warn("failed to read previous row", "sheet", "Weekly", "err", err)
+		return nil
 	}
+	for i := len(rows) - 1; i >= 0; i-- {
+		if len(rows[i]) < 2 {
+			continue
+		}
+		written, err := time.Parse("2006-01-02T15:04:05-0700", fmt.Sprint(rows[i][1]))
+		if err == nil && !written.After(weekAgo) {
+			return snapshots.FromRow(rows[i], 2, columns)
+		}
+	}
+	return nil
+}
 
-	writeRange := fmt.Sprintf("Weekly!A%d", len(resp.Values)+24)
-
-	var vr sheets.ValueRange
+// Run appends the counts of the week since the last sig/node meeting and
+// its PR latencies to the sheet, and optionally publishes the report.
+func Run(args []string) error {
+	fs := flag.NewFlagSet("weekly", flag.ExitOnError)
+	var opts publishOptions
+	fs.StringVar(&opts.Mode, "publish", "", "also publish the report on GitHub: comment or discussion")
+	fs.StringVar(&opts.Repo, "publish-repo", "kubernetes/community", "repository to publish the report in")
+	fs.IntVar(&opts.Issue, "issue", 0, "tracking issue to comment the report on")
+	fs.StringVar(&opts.Category, "discussion-category", "General", "category of the report discussions")
+	fs.BoolVar(&opts.Digest, "digest", false, "only post a digest of the latest stored counts to the NOTIFY_WEBHOOKS")
+	fs.Parse(args)
+
+	if opts.Digest {
+		return postDigest()
+	}
+	if err := opts.check(); err != nil {
+		return err
+	}
+	return run(opts)
+}
 
-	vr.Values = append(vr.Values, values)
+// postDigest posts the digest of the last snapshot in the store without
+// counting again, so it does not add a row next to the hourly run.
+func postDigest() error {
+	lastMeeting, _ := meetingWindow()
 
-	_, err = srv.Spreadsheets.Values.Update(spreadsheetId, writeRange, &vr).ValueInputOption("USER_ENTERED").Do()
+	s, err := snapshots.Latest("weekly")
 	if err != nil {
-		return fmt.Errorf("unable to write data to sheet: %v", err)
+		return err
 	}
-	return nil
+	if s == nil || s.Time.Before(lastMeeting) {
+		return errors.New("no weekly snapshot since the last meeting, is SNAPSHOT_DIR set?")
+	}
+
+	columns := weeklyColumns(lastMeeting, s.Time)
+	previous := previousSnapshot(columns, s.Time)
+	return notify.Send(nil, digest(lastMeeting, columns, *s, previous))
 }
 
-func main() {
-	results, err := getPRs()
+func run(opts publishOptions) error {
+	lastMeeting, dateNow := meetingWindow()
+
+	columns := weeklyColumns(lastMeeting, dateNow)
+	previous := previousSnapshot(columns, dateNow)
+	results, s, err := getPRs(lastMeeting, dateNow, columns, previous, sheet.Hyperlinks(true))
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		return err
 	}
 
-	err = writeToSheet(results)
+	// Both rows go in one batch, so a retried run does not write the
+	// Weekly row again after the Latency row failed.
+	rows := []sheet.Row{{Sheet: "Weekly", FirstRow: 24, Values: results}}
+	latency, err := getLatency(lastMeeting, dateNow)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		logging.Logger.Warn("skipping latency metrics", "err", err)
+	} else {
+		rows = append(rows, sheet.Row{Sheet: "Latency", FirstRow: 2, Values: latency})
+	}
+	if err := sheet.Append("USER_ENTERED", rows...); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		logging.Logger.Info("wrote row", "sheet", r.Sheet, "values", r.Values)
+	}
+
+	if err := snapshots.Append("weekly", s); err != nil {
+		logging.Logger.Warn("failed to store snapshot", "err", err)
+	}
+	if err := alerts.Evaluate("weekly", alerts.History("weekly", s)); err != nil {
+		logging.Logger.Warn("failed to send alerts", "err", err)
 	}
 
-	fmt.Printf("%v\n", results)
+	// The row is written, so a failed publish must not have the run
+	// retried; the next hourly run updates the post in place.
+	if opts.Mode != "" {
+		if err := publish(opts, lastMeeting, dateNow, columns, s, previous); err != nil {
+			logging.Logger.Warn("failed to publish report", "mode", opts.Mode, "err", err)
+		}
+	}
+
+	return nil
 }